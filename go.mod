@@ -6,6 +6,8 @@ require (
 	github.com/ringsq/vcenterapi v0.0.0-20240320174002-fd0df8347ac2
 	github.com/rsapc/netbox v0.0.0-20251205151015-16d375370672
 	github.com/srerun/go-proxmox-pdm v0.0.0-00010101000000-000000000000
+	github.com/vmware/govmomi v0.51.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
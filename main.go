@@ -1,16 +1,15 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
-	"strings"
+	"strconv"
 
 	"github.com/joho/godotenv"
-	nbProvider "github.com/ringsq/netboxvmsync/pkg/providers/netbox"
-	"github.com/ringsq/netboxvmsync/pkg/providers/proxmox"
-	"github.com/ringsq/netboxvmsync/pkg/providers/proxmoxdc"
-	"github.com/ringsq/netboxvmsync/pkg/providers/vmware"
+	"github.com/ringsq/netboxvmsync/pkg/providers"
 	"github.com/ringsq/netboxvmsync/pkg/sync"
 	"github.com/rsapc/netbox"
 )
@@ -25,37 +24,79 @@ var (
 )
 
 type Config struct {
-	NetboxURL      string  `env:"NETBOX_URL"`
-	NetboxToken    string  `env:"NETBOX_TOKEN"`
-	Provider       string  `env:"PROVIDER"`
-	ProviderURL    string  `env:"PROVIDER_URL"`
-	ProviderUser   string  `env:"PROVIDER_USER"`
-	ProviderToken  string  `env:"PROVIDER_TOKEN"`
-	ProviderFilter *string `env:"PROVIDER_FILTER"`
+	NetboxURL      string `env:"NETBOX_URL"`
+	NetboxToken    string `env:"NETBOX_TOKEN"`
+	Provider       string `env:"PROVIDER"`
+	ProviderURL    string `env:"PROVIDER_URL"`
+	ProviderUser   string `env:"PROVIDER_USER"`
+	ProviderToken  string `env:"PROVIDER_TOKEN"`
+	MatchOrder     string `env:"MATCH_ORDER"`
+	FieldOwnership string `env:"FIELD_OWNERSHIP"`
+	ContainerRole  string `env:"CONTAINER_ROLE"`
+	ContainerTag   string `env:"CONTAINER_TAG"`
+	MetadataRules  string `env:"METADATA_RULES"`
+	MetadataFilter string `env:"METADATA_FILTER"`
+	StatusMap      string `env:"STATUS_MAP"`
+	CreateVLANs    string `env:"CREATE_VLANS"`
 }
 
 func main() {
 	cfg := Configure(os.Getenv)
 	nb := netbox.NewClient(cfg.NetboxURL, cfg.NetboxToken, slog.Default())
 	slog.Info("Created Netbox client", "url", cfg.NetboxURL)
-	var provider sync.VMProvider
-	var err error
-
-	switch strings.ToLower(cfg.Provider) {
-	case "proxmoxdc":
-		provider, err = proxmoxdc.NewProxmoxDCProvider(cfg.ProviderURL, cfg.ProviderUser, cfg.ProviderToken, slog.Default())
-	case "proxmox":
-		provider, err = proxmox.NewProxmoxProvider(cfg.ProviderURL, cfg.ProviderUser, cfg.ProviderToken, slog.Default())
-	case "netbox":
-		nbProvClient := netbox.NewClient(cfg.ProviderURL, cfg.ProviderToken, slog.Default())
-		provider, err = nbProvider.NewNetboxProvider(nbProvClient, cfg.ProviderFilter, slog.Default())
-	default:
-		provider, err = vmware.NewVmwareProvider(cfg.ProviderURL, cfg.ProviderUser, cfg.ProviderToken, slog.Default())
+	if cfg.Provider == "" {
+		cfg.Provider = defaultProvider
+	}
+	provider, err := providers.New(cfg.Provider, providers.Config{
+		URL:    cfg.ProviderURL,
+		User:   cfg.ProviderUser,
+		Token:  cfg.ProviderToken,
+		Logger: slog.Default(),
+		Getenv: os.Getenv,
+	})
+	if errors.Is(err, providers.ErrUnknownProvider) {
+		fmt.Fprint(os.Stderr, providers.Usage())
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
+	matchOrder, err := sync.ParseMatchOrder(cfg.MatchOrder)
 	if err != nil {
 		log.Fatal(err)
 	}
-	service := sync.NewSyncService(nb, provider, slog.Default())
+	fieldOwners, err := sync.ParseFieldOwnership(cfg.FieldOwnership)
+	if err != nil {
+		log.Fatal(err)
+	}
+	metadataRules, err := sync.ParseMetadataRules(cfg.MetadataRules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	metadataFilters, err := sync.ParseMetadataFilters(cfg.MetadataFilter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	statusMap, err := sync.ParseStatusMap(cfg.StatusMap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	createVLANs := false
+	if cfg.CreateVLANs != "" {
+		if createVLANs, err = strconv.ParseBool(cfg.CreateVLANs); err != nil {
+			log.Fatalf("invalid CREATE_VLANS %q: %v", cfg.CreateVLANs, err)
+		}
+	}
+	service := sync.NewSyncService(nb, provider, slog.Default(),
+		sync.WithMatchOrder(matchOrder),
+		sync.WithFieldOwnership(fieldOwners),
+		sync.WithNetboxURL(cfg.NetboxURL),
+		sync.WithContainerRole(cfg.ContainerRole),
+		sync.WithContainerTag(cfg.ContainerTag),
+		sync.WithMetadataRules(metadataRules),
+		sync.WithMetadataFilters(metadataFilters),
+		sync.WithStatusMap(statusMap),
+		sync.WithCreateVLANs(createVLANs),
+	)
 	service.StartSync()
 }
 
@@ -68,11 +109,13 @@ func Configure(getenv func(string) string) Config {
 	cfg.ProviderUser = getenv("PROVIDER_USER")
 	cfg.ProviderToken = getenv("PROVIDER_TOKEN")
 	cfg.Provider = getenv("PROVIDER")
-	filter := getenv("PROVIDER_FILTER")
-	if filter == "" {
-		cfg.ProviderFilter = nil
-	} else {
-		cfg.ProviderFilter = &filter
-	}
+	cfg.MatchOrder = getenv("MATCH_ORDER")
+	cfg.FieldOwnership = getenv("FIELD_OWNERSHIP")
+	cfg.ContainerRole = getenv("CONTAINER_ROLE")
+	cfg.ContainerTag = getenv("CONTAINER_TAG")
+	cfg.MetadataRules = getenv("METADATA_RULES")
+	cfg.MetadataFilter = getenv("METADATA_FILTER")
+	cfg.StatusMap = getenv("STATUS_MAP")
+	cfg.CreateVLANs = getenv("CREATE_VLANS")
 	return cfg
 }
@@ -0,0 +1,147 @@
+package ec2
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiVersion is the EC2 Query API version
+const apiVersion = "2016-11-15"
+
+// client is a minimal EC2 Query API client signing its requests with
+// AWS Signature Version 4
+type client struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	// endpoint replaces the regional endpoints when set, eg. a local
+	// EC2-compatible stand-in
+	endpoint string
+	http     *http.Client
+	now      func() time.Time
+}
+
+func newClient(accessKey string, secretKey string) *client {
+	return &client{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		http:      &http.Client{Timeout: 60 * time.Second},
+		now:       time.Now,
+	}
+}
+
+// regionURL returns the endpoint of the region
+func (c *client) regionURL(region string) string {
+	if c.endpoint != "" {
+		return c.endpoint
+	}
+	return fmt.Sprintf("https://ec2.%s.amazonaws.com/", region)
+}
+
+// apiError is the error response of the EC2 API
+type apiError struct {
+	Errors []struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Errors>Error"`
+}
+
+// call runs the action in the region and decodes the XML response into
+// result
+func (c *client) call(ctx context.Context, region string, action string, params url.Values, result any) error {
+	form := url.Values{"Action": {action}, "Version": {apiVersion}}
+	for key, values := range params {
+		form[key] = values
+	}
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.regionURL(region), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	c.sign(req, region, body)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := apiError{}
+		if xml.Unmarshal(data, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("%s in %s: %s: %s", action, region, apiErr.Errors[0].Code, apiErr.Errors[0].Message)
+		}
+		return fmt.Errorf("%s in %s: %s", action, region, resp.Status)
+	}
+	return xml.Unmarshal(data, result)
+}
+
+// sign adds the Signature Version 4 authorization header to the request
+func (c *client) sign(req *http.Request, region string, body string) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         req.URL.Host,
+		"x-amz-date":   amzDate,
+	}
+	names := []string{"content-type", "host", "x-amz-date"}
+	if c.sessionToken != "" {
+		headers["x-amz-security-token"] = c.sessionToken
+		names = append(names, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+	scope := date + "/" + region + "/ec2/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex(canonicalRequest)}, "\n")
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "ec2")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func hashHex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
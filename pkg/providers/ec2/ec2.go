@@ -0,0 +1,409 @@
+// Package ec2 syncs the instances of AWS EC2.  It uses the EC2 Query API
+// directly, so any EC2-compatible endpoint can be used.
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*EC2Provider)(nil)
+
+const gb = 1073741824
+
+// defaultRegion is used to list the regions when none are configured
+const defaultRegion = "us-east-1"
+
+// typeBatch is the number of instance types described per call
+const typeBatch = 100
+
+// Cluster modes decide how instances are grouped into Netbox clusters
+const (
+	// ClusterByVPC creates a cluster per VPC
+	ClusterByVPC = "vpc"
+	// ClusterByZone creates a cluster per availability zone
+	ClusterByZone = "zone"
+)
+
+// Option configures the EC2 provider
+type Option func(*EC2Provider)
+
+// WithRegions sets the regions to sync instead of all enabled regions
+func WithRegions(regions []string) Option {
+	return func(e *EC2Provider) {
+		e.regions = regions
+	}
+}
+
+// WithClusterMode sets how instances are grouped into clusters
+func WithClusterMode(mode string) Option {
+	return func(e *EC2Provider) {
+		if mode != "" {
+			e.clusterMode = strings.ToLower(mode)
+		}
+	}
+}
+
+// WithSessionToken sets the session token of temporary credentials
+func WithSessionToken(token string) Option {
+	return func(e *EC2Provider) {
+		e.client.sessionToken = token
+	}
+}
+
+type EC2Provider struct {
+	client      *client
+	log         pkg.Logger
+	regions     []string
+	clusterMode string
+	// inventory caches the objects of each region for the sync run
+	inventory map[string]*regionInventory
+}
+
+// regionInventory holds the objects of a region used to build the VMs
+type regionInventory struct {
+	instances []instance
+	types     map[string]instanceType
+	volumes   map[string]volume
+	subnets   map[string]subnet
+	vpcs      map[string]vpc
+}
+
+// ParseRegions converts a comma separated list of regions
+func ParseRegions(value string) []string {
+	regions := make([]string, 0)
+	for _, region := range strings.Split(value, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// NewEC2Provider creates a new VM sync provider for AWS EC2.  username and
+// password are the access key ID and secret access key.  baseURL is a custom
+// endpoint used for all regions, eg. a local EC2-compatible stand-in, and
+// is empty to use the AWS regional endpoints.
+func NewEC2Provider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*EC2Provider, error) {
+	e := &EC2Provider{
+		client:      newClient(username, password),
+		log:         logger,
+		clusterMode: ClusterByVPC,
+		inventory:   make(map[string]*regionInventory),
+	}
+	if log, ok := logger.(*slog.Logger); ok {
+		e.log = log.With("provider", e.GetName())
+	}
+	e.client.endpoint = baseURL
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.clusterMode != ClusterByVPC && e.clusterMode != ClusterByZone {
+		return e, fmt.Errorf("invalid cluster mode %q, expected %s or %s", e.clusterMode, ClusterByVPC, ClusterByZone)
+	}
+	if len(e.regions) == 0 {
+		region := defaultRegion
+		e.log.Info("Connecting...", "region", region, "endpoint", e.client.regionURL(region))
+		result := describeRegionsResponse{}
+		if err := e.client.call(context.Background(), region, "DescribeRegions", nil, &result); err != nil {
+			return e, err
+		}
+		for _, r := range result.Regions {
+			e.regions = append(e.regions, r.RegionName)
+		}
+		sort.Strings(e.regions)
+	}
+	e.log.Info("connected to ec2", "regions", e.regions)
+	return e, nil
+}
+
+func (e *EC2Provider) GetName() string {
+	return "EC2"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider.
+// Each region is a datacenter.
+func (e *EC2Provider) GetDatacenters() ([]sync.Datacenter, error) {
+	dcs := make([]sync.Datacenter, 0, len(e.regions))
+	for _, region := range e.regions {
+		dcs = append(dcs, sync.Datacenter{ID: region, Name: region, Description: "AWS region"})
+	}
+	return dcs, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID.  The
+// clusters are the VPCs or availability zones of the region's instances.
+func (e *EC2Provider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	inv, err := e.regionInventory(context.Background(), datacenterID)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, inst := range inv.instances {
+		key, name := e.clusterOf(inv, inst)
+		names[key] = name
+	}
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	clusters := make([]sync.Cluster, 0, len(keys))
+	for _, key := range keys {
+		clusters = append(clusters, sync.Cluster{ID: datacenterID + "/" + key, Name: names[key]})
+	}
+	return clusters, nil
+}
+
+// clusterOf returns the key and name of the cluster of the instance
+func (e *EC2Provider) clusterOf(inv *regionInventory, inst instance) (string, string) {
+	if e.clusterMode == ClusterByZone {
+		return inst.Placement.AvailabilityZone, inst.Placement.AvailabilityZone
+	}
+	if inst.VPCID == "" {
+		return "none", "none"
+	}
+	if name := tagValue(inv.vpcs[inst.VPCID].Tags, "Name"); name != "" {
+		return inst.VPCID, name
+	}
+	return inst.VPCID, inst.VPCID
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (e *EC2Provider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	region, key, ok := strings.Cut(clusterID, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid cluster ID %s", clusterID)
+	}
+	inv, err := e.regionInventory(context.Background(), region)
+	if err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0)
+	for _, inst := range inv.instances {
+		if k, _ := e.clusterOf(inv, inst); k != key {
+			continue
+		}
+		if inst.State.Name == "terminated" {
+			continue
+		}
+		vms = append(vms, e.toVM(inv, inst))
+	}
+	return vms, nil
+}
+
+// regionInventory loads the instances of the region along with their
+// instance types, volumes, subnets and VPCs, unless already loaded
+func (e *EC2Provider) regionInventory(ctx context.Context, region string) (*regionInventory, error) {
+	if inv, ok := e.inventory[region]; ok {
+		return inv, nil
+	}
+	inv := &regionInventory{
+		types:   make(map[string]instanceType),
+		volumes: make(map[string]volume),
+		subnets: make(map[string]subnet),
+		vpcs:    make(map[string]vpc),
+	}
+	instancePages, err := pages(ctx, e.client, region, "DescribeInstances", url.Values{"MaxResults": {"1000"}},
+		func(p describeInstancesResponse) string { return p.NextToken })
+	if err != nil {
+		return nil, err
+	}
+	typeNames := make(map[string]bool)
+	for _, page := range instancePages {
+		for _, reservation := range page.Reservations {
+			for _, inst := range reservation.Instances {
+				inv.instances = append(inv.instances, inst)
+				typeNames[inst.InstanceType] = true
+			}
+		}
+	}
+	if err = e.loadTypes(ctx, region, inv, typeNames); err != nil {
+		e.log.Warn("could not retrieve instance types", "region", region, "error", err)
+	}
+	if volumePages, err := pages(ctx, e.client, region, "DescribeVolumes", url.Values{"MaxResults": {"500"}},
+		func(p describeVolumesResponse) string { return p.NextToken }); err == nil {
+		for _, page := range volumePages {
+			for _, v := range page.Volumes {
+				inv.volumes[v.VolumeID] = v
+			}
+		}
+	} else {
+		e.log.Warn("could not retrieve volumes", "region", region, "error", err)
+	}
+	if subnetPages, err := pages(ctx, e.client, region, "DescribeSubnets", url.Values{"MaxResults": {"1000"}},
+		func(p describeSubnetsResponse) string { return p.NextToken }); err == nil {
+		for _, page := range subnetPages {
+			for _, s := range page.Subnets {
+				inv.subnets[s.SubnetID] = s
+			}
+		}
+	} else {
+		e.log.Warn("could not retrieve subnets", "region", region, "error", err)
+	}
+	if vpcPages, err := pages(ctx, e.client, region, "DescribeVpcs", url.Values{"MaxResults": {"1000"}},
+		func(p describeVpcsResponse) string { return p.NextToken }); err == nil {
+		for _, page := range vpcPages {
+			for _, v := range page.VPCs {
+				inv.vpcs[v.VPCID] = v
+			}
+		}
+	} else {
+		e.log.Warn("could not retrieve vpcs", "region", region, "error", err)
+	}
+	e.inventory[region] = inv
+	return inv, nil
+}
+
+// loadTypes describes the instance types in batches
+func (e *EC2Provider) loadTypes(ctx context.Context, region string, inv *regionInventory, typeNames map[string]bool) error {
+	names := make([]string, 0, len(typeNames))
+	for name := range typeNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for start := 0; start < len(names); start += typeBatch {
+		params := url.Values{}
+		for i, name := range names[start:min(start+typeBatch, len(names))] {
+			params.Set(fmt.Sprintf("InstanceType.%d", i+1), name)
+		}
+		typePages, err := pages(ctx, e.client, region, "DescribeInstanceTypes", params,
+			func(p describeInstanceTypesResponse) string { return p.NextToken })
+		if err != nil {
+			return err
+		}
+		for _, page := range typePages {
+			for _, t := range page.InstanceTypes {
+				inv.types[t.InstanceType] = t
+			}
+		}
+	}
+	return nil
+}
+
+// pages calls the action until the response has no next token, returning
+// every page of the response
+func pages[T any](ctx context.Context, c *client, region string, action string, params url.Values, nextToken func(T) string) ([]T, error) {
+	result := make([]T, 0)
+	for token := ""; ; {
+		query := url.Values{}
+		for key, values := range params {
+			query[key] = values
+		}
+		if token != "" {
+			query.Set("NextToken", token)
+		}
+		var page T
+		if err := c.call(ctx, region, action, query, &page); err != nil {
+			return nil, err
+		}
+		result = append(result, page)
+		if token = nextToken(page); token == "" {
+			return result, nil
+		}
+	}
+}
+
+// toVM converts the instance
+func (e *EC2Provider) toVM(inv *regionInventory, inst instance) sync.VM {
+	vm := sync.VM{ID: inst.InstanceID, Name: tagValue(inst.Tags, "Name"), Type: sync.VMTypeVirtualMachine}
+	if vm.Name == "" {
+		vm.Name = inst.InstanceID
+	}
+	vm.PowerState = inst.State.Name
+	if inst.State.Name == "running" {
+		vm.Status = "active"
+	} else {
+		vm.Status = "offline"
+	}
+	vm.Metadata = make(map[string]string)
+	vm.Tags = make([]string, 0)
+	for _, t := range inst.Tags {
+		vm.Metadata[t.Key] = t.Value
+		if tag := netboxTag(t); tag != "" {
+			vm.Tags = append(vm.Tags, tag)
+		}
+	}
+	sort.Strings(vm.Tags)
+	vm.Metadata[sync.MetaZone] = inst.Placement.AvailabilityZone
+	vm.Metadata[sync.MetaVPC] = inst.VPCID
+	vm.Metadata[sync.MetaInstanceType] = inst.InstanceType
+	t := inv.types[inst.InstanceType]
+	vm.VCPUs = float32(t.VCPUInfo.DefaultVCPUs)
+	if inst.CPUOptions.CoreCount > 0 {
+		vm.VCPUs = float32(inst.CPUOptions.CoreCount * max(inst.CPUOptions.ThreadsPerCore, 1))
+	}
+	vm.Memory = t.MemoryInfo.SizeInMiB
+	var diskspace int64
+	for _, mapping := range inst.BlockDeviceMapping {
+		if mapping.EBS.VolumeID == "" {
+			continue
+		}
+		disk := sync.Disk{ID: mapping.EBS.VolumeID, Name: mapping.DeviceName}
+		if v, ok := inv.volumes[mapping.EBS.VolumeID]; ok {
+			disk.Size = v.Size * gb
+			disk.Description = v.VolumeType
+		}
+		vm.Disks = append(vm.Disks, disk)
+		diskspace += disk.Size
+	}
+	vm.Diskspace = int(diskspace / gb)
+	enis := append([]networkInterface(nil), inst.NetworkInterfaces...)
+	sort.Slice(enis, func(i, j int) bool { return enis[i].Attachment.DeviceIndex < enis[j].Attachment.DeviceIndex })
+	vm.Network = make([]sync.NIC, 0, len(enis))
+	for _, eni := range enis {
+		vm.Network = append(vm.Network, toNIC(inv, eni))
+	}
+	return vm
+}
+
+// netboxTag returns the Netbox tag of the instance tag, key:value or the
+// key if the value is empty.  The Name tag, which is the VM name, and the
+// aws: tags set by AWS services are left out.
+func netboxTag(t tag) string {
+	if t.Key == "Name" || strings.HasPrefix(t.Key, "aws:") {
+		return ""
+	}
+	if t.Value == "" {
+		return t.Key
+	}
+	return t.Key + ":" + t.Value
+}
+
+// toNIC converts the elastic network interface.  Private addresses use the
+// prefix length of the subnet, public addresses are host routes.
+func toNIC(inv *regionInventory, eni networkInterface) sync.NIC {
+	nic := sync.NIC{ID: eni.NetworkInterfaceID, Name: fmt.Sprintf("eth%d", eni.Attachment.DeviceIndex), MAC: strings.ToUpper(eni.MacAddress)}
+	nic.Description = eni.Description
+	nic.Network = eni.SubnetID
+	enabled := eni.Attachment.Status == "attached"
+	nic.Enabled = &enabled
+	prefix := "32"
+	if s, ok := inv.subnets[eni.SubnetID]; ok {
+		if name := tagValue(s.Tags, "Name"); name != "" {
+			nic.Network = name
+		}
+		if _, ipnet, err := net.ParseCIDR(s.CIDRBlock); err == nil {
+			ones, _ := ipnet.Mask.Size()
+			prefix = fmt.Sprint(ones)
+		}
+	}
+	for _, addr := range eni.PrivateIPAddresses {
+		nic.AddIP(addr.PrivateIPAddress+"/"+prefix, sync.IPSourceConfig)
+		if addr.Association.PublicIP != "" {
+			nic.AddIP(addr.Association.PublicIP+"/32", sync.IPSourceConfig)
+		}
+	}
+	for _, addr := range eni.IPv6Addresses {
+		nic.AddIP(addr.IPv6Address+"/128", sync.IPSourceConfig)
+	}
+	return nic
+}
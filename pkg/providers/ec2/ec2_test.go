@@ -0,0 +1,182 @@
+package ec2
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	gosync "sync"
+	"testing"
+)
+
+const (
+	testRegion    = "us-test-1"
+	testAccessKey = "AKIDTEST"
+	// testTypes is the number of instance types, one more than a batch
+	testTypes = typeBatch + 1
+)
+
+// standIn is a local stand-in of the EC2 Query API
+type standIn struct {
+	mu gosync.Mutex
+	// typeBatches holds the instance types of each DescribeInstanceTypes call
+	typeBatches [][]string
+	// instancePages counts the DescribeInstances calls
+	instancePages int
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.PostForm.Get("Action")
+	region := testRegion
+	if action == "DescribeRegions" {
+		region = defaultRegion
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+testAccessKey+"/") || !strings.Contains(auth, "/"+region+"/ec2/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `<Response><Errors><Error><Code>AuthFailure</Code><Message>%s</Message></Error></Errors></Response>`, auth)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch action {
+	case "DescribeRegions":
+		fmt.Fprintf(w, `<DescribeRegionsResponse><regionInfo><item><regionName>%s</regionName></item></regionInfo></DescribeRegionsResponse>`, testRegion)
+	case "DescribeInstances":
+		s.instancePages++
+		s.describeInstances(w, r.PostForm.Get("NextToken"))
+	case "DescribeInstanceTypes":
+		names := make([]string, 0)
+		items := make([]string, 0)
+		for i := 1; r.PostForm.Has(fmt.Sprintf("InstanceType.%d", i)); i++ {
+			name := r.PostForm.Get(fmt.Sprintf("InstanceType.%d", i))
+			names = append(names, name)
+			items = append(items, fmt.Sprintf(`<item><instanceType>%s</instanceType><vCpuInfo><defaultVCpus>2</defaultVCpus></vCpuInfo><memoryInfo><sizeInMiB>4096</sizeInMiB></memoryInfo></item>`, name))
+		}
+		s.typeBatches = append(s.typeBatches, names)
+		fmt.Fprintf(w, `<DescribeInstanceTypesResponse><instanceTypeSet>%s</instanceTypeSet></DescribeInstanceTypesResponse>`, strings.Join(items, ""))
+	case "DescribeVolumes":
+		fmt.Fprint(w, `<DescribeVolumesResponse><volumeSet><item><volumeId>vol-1</volumeId><size>30</size><volumeType>gp3</volumeType></item></volumeSet></DescribeVolumesResponse>`)
+	case "DescribeSubnets":
+		fmt.Fprint(w, `<DescribeSubnetsResponse><subnetSet><item><subnetId>subnet-1</subnetId><cidrBlock>10.0.1.0/24</cidrBlock><tagSet><item><key>Name</key><value>app</value></item></tagSet></item></subnetSet></DescribeSubnetsResponse>`)
+	case "DescribeVpcs":
+		fmt.Fprint(w, `<DescribeVpcsResponse><vpcSet><item><vpcId>vpc-1</vpcId><tagSet><item><key>Name</key><value>prod</value></item></tagSet></item></vpcSet></DescribeVpcsResponse>`)
+	default:
+		http.Error(w, "unknown action "+action, http.StatusBadRequest)
+	}
+}
+
+// describeInstances returns the web instance on the first page and the
+// filler instances of the other instance types on the second
+func (s *standIn) describeInstances(w http.ResponseWriter, token string) {
+	if token == "" {
+		fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+<instanceId>i-web</instanceId><instanceType>type-0</instanceType><vpcId>vpc-1</vpcId>
+<placement><availabilityZone>us-test-1a</availabilityZone></placement>
+<instanceState><name>running</name></instanceState>
+<tagSet><item><key>Name</key><value>web1</value></item><item><key>team</key><value>web</value></item><item><key>aws:autoscaling:groupName</key><value>asg</value></item><item><key>backup</key><value></value></item></tagSet>
+<blockDeviceMapping><item><deviceName>/dev/xvda</deviceName><ebs><volumeId>vol-1</volumeId></ebs></item></blockDeviceMapping>
+<networkInterfaceSet>
+<item><networkInterfaceId>eni-2</networkInterfaceId><macAddress>0a:00:00:00:00:02</macAddress><subnetId>subnet-2</subnetId>
+<attachment><deviceIndex>1</deviceIndex><status>detached</status></attachment>
+<privateIpAddressesSet><item><privateIpAddress>10.0.2.9</privateIpAddress></item></privateIpAddressesSet></item>
+<item><networkInterfaceId>eni-1</networkInterfaceId><macAddress>0a:00:00:00:00:01</macAddress><subnetId>subnet-1</subnetId><description>primary</description>
+<attachment><deviceIndex>0</deviceIndex><status>attached</status></attachment>
+<privateIpAddressesSet><item><privateIpAddress>10.0.1.5</privateIpAddress><association><publicIp>198.51.100.7</publicIp></association></item><item><privateIpAddress>10.0.1.6</privateIpAddress></item></privateIpAddressesSet>
+<ipv6AddressesSet><item><ipv6Address>2001:db8::5</ipv6Address></item></ipv6AddressesSet></item>
+</networkInterfaceSet>
+</item></instancesSet></item></reservationSet><nextToken>page-2</nextToken></DescribeInstancesResponse>`)
+		return
+	}
+	if token != "page-2" {
+		http.Error(w, "invalid token "+token, http.StatusBadRequest)
+		return
+	}
+	items := make([]string, 0)
+	for i := 1; i < testTypes; i++ {
+		items = append(items, fmt.Sprintf(`<item><instanceId>i-%d</instanceId><instanceType>type-%d</instanceType><vpcId>vpc-1</vpcId><instanceState><name>stopped</name></instanceState></item>`, i, i))
+	}
+	fmt.Fprintf(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>%s</instancesSet></item></reservationSet></DescribeInstancesResponse>`, strings.Join(items, ""))
+}
+
+func TestEC2Provider(t *testing.T) {
+	stand := &standIn{}
+	srv := httptest.NewServer(stand)
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e, err := NewEC2Provider(srv.URL+"/", testAccessKey, "secret", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dcs, err := e.GetDatacenters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dcs) != 1 || dcs[0].ID != testRegion {
+		t.Fatalf("datacenters %+v, expected the region of DescribeRegions", dcs)
+	}
+	clusters, err := e.GetDcClusters(testRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0].ID != testRegion+"/vpc-1" || clusters[0].Name != "prod" {
+		t.Fatalf("clusters %+v, expected the prod VPC", clusters)
+	}
+	vms, err := e.GetClusterVMs(testRegion + "/vpc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stand.instancePages != 2 {
+		t.Errorf("%d DescribeInstances calls, expected 2 pages", stand.instancePages)
+	}
+	if len(vms) != testTypes {
+		t.Fatalf("%d VMs, expected %d from both pages", len(vms), testTypes)
+	}
+	if len(stand.typeBatches) != 2 || len(stand.typeBatches[0]) != typeBatch || len(stand.typeBatches[1]) != 1 {
+		sizes := make([]int, 0)
+		for _, batch := range stand.typeBatches {
+			sizes = append(sizes, len(batch))
+		}
+		t.Fatalf("instance type batches of %v, expected %d and 1", sizes, typeBatch)
+	}
+	for _, vm := range vms[1:] {
+		if vm.VCPUs != 2 || vm.Memory != 4096 || vm.Status != "offline" {
+			t.Fatalf("VM %s is %+v, expected the details of its instance type", vm.ID, vm)
+		}
+	}
+
+	web := vms[0]
+	if web.ID != "i-web" || web.Name != "web1" || web.Status != "active" || web.VCPUs != 2 || web.Memory != 4096 || web.Diskspace != 30 {
+		t.Errorf("web1 is %+v", web)
+	}
+	if expected := []string{"backup", "team:web"}; !reflect.DeepEqual(web.Tags, expected) {
+		t.Errorf("web1 tags %v, expected %v", web.Tags, expected)
+	}
+	if web.Metadata["team"] != "web" || web.Metadata["vpc"] != "vpc-1" || web.Metadata["instance_type"] != "type-0" || web.Metadata["zone"] != "us-test-1a" {
+		t.Errorf("web1 metadata %v", web.Metadata)
+	}
+	if len(web.Network) != 2 {
+		t.Fatalf("web1 NICs %+v", web.Network)
+	}
+	eth0, eth1 := web.Network[0], web.Network[1]
+	if eth0.ID != "eni-1" || eth0.Name != "eth0" || eth0.MAC != "0A:00:00:00:00:01" || eth0.Network != "app" || eth0.Enabled == nil || !*eth0.Enabled {
+		t.Errorf("eth0 is %+v", eth0)
+	}
+	if expected := []string{"10.0.1.5/24", "198.51.100.7/32", "10.0.1.6/24", "2001:db8::5/128"}; !reflect.DeepEqual(eth0.IP, expected) {
+		t.Errorf("eth0 IPs %v, expected %v", eth0.IP, expected)
+	}
+	if eth1.ID != "eni-2" || eth1.Name != "eth1" || eth1.Network != "subnet-2" || eth1.Enabled == nil || *eth1.Enabled {
+		t.Errorf("eth1 is %+v", eth1)
+	}
+	if expected := []string{"10.0.2.9/32"}; !reflect.DeepEqual(eth1.IP, expected) {
+		t.Errorf("eth1 IPs %v, expected %v for an unknown subnet", eth1.IP, expected)
+	}
+}
@@ -0,0 +1,120 @@
+package ec2
+
+type tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+// tagValue returns the value of the tag key, or an empty string
+func tagValue(tags []tag, key string) string {
+	for _, t := range tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+type instance struct {
+	InstanceID   string `xml:"instanceId"`
+	InstanceType string `xml:"instanceType"`
+	VPCID        string `xml:"vpcId"`
+	Placement    struct {
+		AvailabilityZone string `xml:"availabilityZone"`
+	} `xml:"placement"`
+	State struct {
+		Name string `xml:"name"`
+	} `xml:"instanceState"`
+	Tags               []tag `xml:"tagSet>item"`
+	BlockDeviceMapping []struct {
+		DeviceName string `xml:"deviceName"`
+		EBS        struct {
+			VolumeID string `xml:"volumeId"`
+		} `xml:"ebs"`
+	} `xml:"blockDeviceMapping>item"`
+	NetworkInterfaces []networkInterface `xml:"networkInterfaceSet>item"`
+	CPUOptions        struct {
+		CoreCount      int `xml:"coreCount"`
+		ThreadsPerCore int `xml:"threadsPerCore"`
+	} `xml:"cpuOptions"`
+}
+
+type networkInterface struct {
+	NetworkInterfaceID string `xml:"networkInterfaceId"`
+	MacAddress         string `xml:"macAddress"`
+	SubnetID           string `xml:"subnetId"`
+	Description        string `xml:"description"`
+	Attachment         struct {
+		DeviceIndex int    `xml:"deviceIndex"`
+		Status      string `xml:"status"`
+	} `xml:"attachment"`
+	PrivateIPAddresses []struct {
+		PrivateIPAddress string `xml:"privateIpAddress"`
+		Association      struct {
+			PublicIP string `xml:"publicIp"`
+		} `xml:"association"`
+	} `xml:"privateIpAddressesSet>item"`
+	IPv6Addresses []struct {
+		IPv6Address string `xml:"ipv6Address"`
+	} `xml:"ipv6AddressesSet>item"`
+}
+
+type describeInstancesResponse struct {
+	Reservations []struct {
+		Instances []instance `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+type instanceType struct {
+	InstanceType string `xml:"instanceType"`
+	VCPUInfo     struct {
+		DefaultVCPUs int `xml:"defaultVCpus"`
+	} `xml:"vCpuInfo"`
+	MemoryInfo struct {
+		SizeInMiB int `xml:"sizeInMiB"`
+	} `xml:"memoryInfo"`
+}
+
+type describeInstanceTypesResponse struct {
+	InstanceTypes []instanceType `xml:"instanceTypeSet>item"`
+	NextToken     string         `xml:"nextToken"`
+}
+
+type volume struct {
+	VolumeID   string `xml:"volumeId"`
+	Size       int64  `xml:"size"`
+	VolumeType string `xml:"volumeType"`
+}
+
+type describeVolumesResponse struct {
+	Volumes   []volume `xml:"volumeSet>item"`
+	NextToken string   `xml:"nextToken"`
+}
+
+type subnet struct {
+	SubnetID  string `xml:"subnetId"`
+	CIDRBlock string `xml:"cidrBlock"`
+	Tags      []tag  `xml:"tagSet>item"`
+}
+
+type describeSubnetsResponse struct {
+	Subnets   []subnet `xml:"subnetSet>item"`
+	NextToken string   `xml:"nextToken"`
+}
+
+type vpc struct {
+	VPCID string `xml:"vpcId"`
+	Tags  []tag  `xml:"tagSet>item"`
+}
+
+type describeVpcsResponse struct {
+	VPCs      []vpc  `xml:"vpcSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+type describeRegionsResponse struct {
+	Regions []struct {
+		RegionName string `xml:"regionName"`
+	} `xml:"regionInfo>item"`
+}
@@ -0,0 +1,25 @@
+package ec2
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "ec2",
+		Description: "AWS EC2 instances, PROVIDER_URL optionally overrides the endpoint",
+		Settings: []providers.Setting{
+			{Name: "EC2_REGIONS", Description: "the comma separated regions to sync, all enabled regions by default"},
+			{Name: "EC2_CLUSTER_MODE", Default: ClusterByVPC, Description: "how instances are grouped into Netbox clusters, vpc or zone"},
+			{Name: "EC2_SESSION_TOKEN", Description: "the session token of temporary credentials"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewEC2Provider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithRegions(ParseRegions(cfg.Get("EC2_REGIONS"))),
+				WithClusterMode(cfg.Get("EC2_CLUSTER_MODE")),
+				WithSessionToken(cfg.Get("EC2_SESSION_TOKEN")),
+			)
+		},
+	})
+}
@@ -0,0 +1,246 @@
+// Package external syncs the VMs reported by an external program, so
+// providers for other platforms can be written in any language.  The
+// program is run once per call and exchanges JSON over its standard input
+// and output as described in PROTOCOL.md.
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*ExternalProvider)(nil)
+
+// DefaultTimeout is the time allowed for a single run of the program
+const DefaultTimeout = 5 * time.Minute
+
+// Environment variables passed to the program
+const (
+	EnvProtocolVersion = "NETBOXVMSYNC_PROTOCOL_VERSION"
+	EnvProviderUser    = "NETBOXVMSYNC_PROVIDER_USER"
+	EnvProviderToken   = "NETBOXVMSYNC_PROVIDER_TOKEN"
+)
+
+// envPrefix is the prefix of the environment variables of the sync passed
+// to the program
+const envPrefix = "NETBOXVMSYNC_"
+
+// passedEnv are the other environment variables passed to the program.  The
+// rest, eg. NETBOX_TOKEN, is not handed to a third-party program.
+var passedEnv = []string{"PATH", "HOME", "LANG", "TZ"}
+
+// Option configures the external provider
+type Option func(*ExternalProvider)
+
+// WithTimeout sets the time allowed for a single run of the program
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *ExternalProvider) {
+		if timeout > 0 {
+			e.timeout = timeout
+		}
+	}
+}
+
+type ExternalProvider struct {
+	log     pkg.Logger
+	program string
+	args    []string
+	env     []string
+	timeout time.Duration
+}
+
+// NewExternalProvider creates a new VM sync provider running the program of
+// baseURL, a command line split on spaces.  username and password are
+// passed to the program in environment variables rather than arguments, so
+// they are not visible in the process list.  The program only gets a minimal
+// environment, without the Netbox and provider credentials of the sync.
+func NewExternalProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*ExternalProvider, error) {
+	e := &ExternalProvider{log: logger, timeout: DefaultTimeout}
+	if log, ok := logger.(*slog.Logger); ok {
+		e.log = log.With("provider", e.GetName())
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	fields := strings.Fields(baseURL)
+	if len(fields) == 0 {
+		return e, errors.New("no external provider program given")
+	}
+	program, err := exec.LookPath(fields[0])
+	if err != nil {
+		return e, err
+	}
+	e.program, e.args = program, fields[1:]
+	e.env = append(programEnv(os.Environ()),
+		fmt.Sprintf("%s=%d", EnvProtocolVersion, ProtocolVersion),
+		EnvProviderUser+"="+username,
+		EnvProviderToken+"="+password,
+	)
+	e.log.Info("using external provider", "program", e.program, "timeout", e.timeout)
+	return e, nil
+}
+
+// programEnv returns the variables of environ passed to the program
+func programEnv(environ []string) []string {
+	env := make([]string, 0)
+	for _, v := range environ {
+		name, _, _ := strings.Cut(v, "=")
+		if strings.HasPrefix(name, envPrefix) || slices.Contains(passedEnv, name) {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+func (e *ExternalProvider) GetName() string {
+	return "external"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider
+func (e *ExternalProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	resp, err := e.call(request{Method: MethodGetDatacenters})
+	if err != nil {
+		return nil, err
+	}
+	dcs := make([]sync.Datacenter, 0, len(resp.Datacenters))
+	for _, dc := range resp.Datacenters {
+		if dc.ID == "" {
+			dc.ID = dc.Name
+		}
+		if dc.ID == "" {
+			return nil, fmt.Errorf("%s: datacenter has no id or name", MethodGetDatacenters)
+		}
+		dcs = append(dcs, sync.Datacenter{ID: dc.ID, Name: dc.Name, Description: dc.Description})
+	}
+	return dcs, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID
+func (e *ExternalProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	resp, err := e.call(request{Method: MethodGetDcClusters, Params: params{DatacenterID: datacenterID}})
+	if err != nil {
+		return nil, err
+	}
+	clusters := make([]sync.Cluster, 0, len(resp.Clusters))
+	for _, cl := range resp.Clusters {
+		if cl.ID == "" {
+			cl.ID = cl.Name
+		}
+		if cl.ID == "" {
+			return nil, fmt.Errorf("%s: cluster has no id or name", MethodGetDcClusters)
+		}
+		clusters = append(clusters, sync.Cluster{ID: cl.ID, Name: cl.Name, Description: cl.Description})
+	}
+	return clusters, nil
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (e *ExternalProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	resp, err := e.call(request{Method: MethodGetClusterVMs, Params: params{ClusterID: clusterID}})
+	if err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0, len(resp.VMs))
+	for _, v := range resp.VMs {
+		if err := v.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", MethodGetClusterVMs, err)
+		}
+		vms = append(vms, v.toVM())
+	}
+	return vms, nil
+}
+
+// call runs the program with the request and returns its response.  The
+// standard error of the program is logged line by line.
+func (e *ExternalProvider) call(req request) (response, error) {
+	req.Version = ProtocolVersion
+	resp := response{}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	var stdout bytes.Buffer
+	stderr := &logWriter{log: e.log, method: req.Method}
+	cmd := exec.CommandContext(ctx, e.program, e.args...)
+	cmd.Env = e.env
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	// Do not wait for children of the program holding the pipes open
+	cmd.WaitDelay = 5 * time.Second
+	err = cmd.Run()
+	stderr.flush()
+	if ctx.Err() == context.DeadlineExceeded {
+		return resp, fmt.Errorf("%s: program timed out after %s", req.Method, e.timeout)
+	}
+	if err != nil {
+		if stderr.last != "" {
+			return resp, fmt.Errorf("%s: %w: %s", req.Method, err, stderr.last)
+		}
+		return resp, fmt.Errorf("%s: %w", req.Method, err)
+	}
+	decoder := json.NewDecoder(&stdout)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&resp); err != nil {
+		return resp, fmt.Errorf("%s: invalid response: %w", req.Method, err)
+	}
+	if resp.Version != ProtocolVersion {
+		return resp, fmt.Errorf("%s: unsupported protocol version %d, expected %d", req.Method, resp.Version, ProtocolVersion)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("%s: %s", req.Method, resp.Error)
+	}
+	return resp, nil
+}
+
+// logWriter logs the lines written to it
+type logWriter struct {
+	log    pkg.Logger
+	method string
+	buf    []byte
+	// last is the last line written, used in errors
+	last string
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		w.line(string(w.buf[:idx]))
+		w.buf = w.buf[idx+1:]
+	}
+}
+
+// flush logs the remaining output without a trailing newline
+func (w *logWriter) flush() {
+	if len(w.buf) > 0 {
+		w.line(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *logWriter) line(line string) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	w.last = line
+	w.log.Info("program output", "method", w.method, "stderr", line)
+}
@@ -0,0 +1,122 @@
+package external
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// program is the test program, run with the mode as its argument
+const program = "testdata/provider.sh"
+
+func newTestProvider(t *testing.T, mode string, opts ...Option) *ExternalProvider {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e, err := NewExternalProvider(program+" "+mode, "user", "secret", logger, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExternalProvider(t *testing.T) {
+	e := newTestProvider(t, "valid")
+	dcs, err := e.GetDatacenters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []sync.Datacenter{{ID: "East", Name: "East", Description: "East site"}}; !reflect.DeepEqual(dcs, expected) {
+		t.Fatalf("datacenters %+v, expected %+v", dcs, expected)
+	}
+	clusters, err := e.GetDcClusters("East")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []sync.Cluster{{ID: "East/cl1", Name: "cl1"}}; !reflect.DeepEqual(clusters, expected) {
+		t.Fatalf("clusters %+v, expected %+v", clusters, expected)
+	}
+	vms, err := e.GetClusterVMs("East/cl1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 2 {
+		t.Fatalf("%d VMs, expected 2", len(vms))
+	}
+	web, db := vms[0], vms[1]
+	if web.ID != "vm-1" || web.Name != "web1" || web.Memory != 4096 || web.Diskspace != 60 || web.VCPUs != 2 ||
+		web.Status != "active" || web.PowerState != "Running" || web.Type != sync.VMTypeVirtualMachine || web.Err != nil {
+		t.Errorf("web1 is %+v", web)
+	}
+	if !reflect.DeepEqual(web.Tags, []string{"web"}) || web.Metadata["owner"] != "web-team" {
+		t.Errorf("web1 tags %v, metadata %v", web.Tags, web.Metadata)
+	}
+	if len(web.Disks) != 1 || web.Disks[0].Size != 64424509440 {
+		t.Errorf("web1 disks %+v", web.Disks)
+	}
+	if len(web.Network) != 1 {
+		t.Fatalf("web1 NICs %+v", web.Network)
+	}
+	nic := web.Network[0]
+	if nic.ID != "eth0" || nic.MAC != "00:15:5D:01:02:03" || nic.VLAN != 10 || !reflect.DeepEqual(nic.IP, []string{"10.0.0.5/24"}) ||
+		nic.IPSources["10.0.0.5/24"] != sync.IPSourceAgent {
+		t.Errorf("web1 NIC %+v", nic)
+	}
+	if db.ID != "db1" || db.Err == nil || db.Err.Error() != "details not available" {
+		t.Errorf("db1 is %+v, expected the error of the VM", db)
+	}
+}
+
+func TestExternalProviderErrors(t *testing.T) {
+	tests := []struct {
+		mode     string
+		opts     []Option
+		expected string
+	}{
+		{mode: "version", expected: "GetDatacenters: unsupported protocol version 2, expected 1"},
+		{mode: "unknown", expected: `GetDatacenters: invalid response: json: unknown field "site"`},
+		{mode: "fail", expected: "GetDatacenters: exit status 3: hypervisor unreachable"},
+		{mode: "sleep", opts: []Option{WithTimeout(200 * time.Millisecond)}, expected: "GetDatacenters: program timed out after 200ms"},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			e := newTestProvider(t, test.mode, test.opts...)
+			start := time.Now()
+			_, err := e.GetDatacenters()
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf("error %v, expected %s", err, test.expected)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("call took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestExternalProviderEnv(t *testing.T) {
+	t.Setenv("NETBOX_TOKEN", "netbox-secret")
+	t.Setenv("PROVIDER_TOKEN", "provider-secret")
+	t.Setenv("NETBOXVMSYNC_SITE", "east")
+	e := newTestProvider(t, "env")
+	dcs, err := e.GetDatacenters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dcs) != 1 || dcs[0].Name != "secret east" {
+		t.Fatalf("datacenters %+v, expected the token and NETBOXVMSYNC_SITE", dcs)
+	}
+	if dcs[0].Description != "" {
+		t.Errorf("the program got the credentials of the sync: %q", dcs[0].Description)
+	}
+}
+
+func TestProgramEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "NETBOX_TOKEN=x", "HOME=/root", "NETBOXVMSYNC_SITE=east", "PROVIDER_TOKEN=y", "LANG=C", "TZ=UTC", "PATHS=z"}
+	expected := []string{"PATH=/bin", "HOME=/root", "NETBOXVMSYNC_SITE=east", "LANG=C", "TZ=UTC"}
+	if env := programEnv(environ); !reflect.DeepEqual(env, expected) {
+		t.Errorf("environment %v, expected %v", env, expected)
+	}
+}
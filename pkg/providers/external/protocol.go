@@ -0,0 +1,193 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// ProtocolVersion is the version of the JSON protocol spoken with the
+// external program.  See PROTOCOL.md.
+const ProtocolVersion = 1
+
+// Methods of the protocol
+const (
+	MethodGetDatacenters = "GetDatacenters"
+	MethodGetDcClusters  = "GetDcClusters"
+	MethodGetClusterVMs  = "GetClusterVMs"
+)
+
+// request is written to the standard input of the program
+type request struct {
+	Version int    `json:"version"`
+	Method  string `json:"method"`
+	Params  params `json:"params"`
+}
+
+type params struct {
+	DatacenterID string `json:"datacenter_id,omitempty"`
+	ClusterID    string `json:"cluster_id,omitempty"`
+}
+
+// response is read from the standard output of the program
+type response struct {
+	Version     int          `json:"version"`
+	Error       string       `json:"error"`
+	Datacenters []Datacenter `json:"datacenters"`
+	Clusters    []Cluster    `json:"clusters"`
+	VMs         []VM         `json:"vms"`
+}
+
+// Datacenter is a sync.Datacenter in the protocol
+type Datacenter struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Cluster is a sync.Cluster in the protocol
+type Cluster struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// VM is a sync.VM in the protocol.  Memory is in MB and Diskspace in GB.
+type VM struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Memory       int               `json:"memory"`
+	Diskspace    int               `json:"disk"`
+	VCPUs        float32           `json:"vcpus"`
+	Status       string            `json:"status"`
+	PowerState   string            `json:"power_state"`
+	Serial       string            `json:"serial"`
+	Type         string            `json:"type"`
+	Role         string            `json:"role"`
+	Tenant       string            `json:"tenant"`
+	Tags         []string          `json:"tags"`
+	Metadata     map[string]string `json:"metadata"`
+	CustomFields map[string]any    `json:"custom_fields"`
+	Disks        []Disk            `json:"disks"`
+	NICs         []NIC             `json:"nics"`
+	// Error is set when the program could not retrieve the VM.  The VM is
+	// not synced, but is not pruned either.
+	Error string `json:"error"`
+}
+
+// Disk is a sync.Disk in the protocol.  Size is in bytes.
+type Disk struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	Description string `json:"description"`
+}
+
+// NIC is a sync.NIC in the protocol.  IPs are addresses with their prefix
+// length, eg. 10.0.0.5/24.
+type NIC struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	MAC         string   `json:"mac"`
+	IPs         []string `json:"ips"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Network     string   `json:"network"`
+	Switch      string   `json:"switch"`
+	VLAN        int      `json:"vlan"`
+	Tagged      bool     `json:"tagged"`
+	Enabled     *bool    `json:"enabled"`
+	// Source is the source of the IPs: config (the default), cloud-init or
+	// agent.  Agent addresses are preferred over the others.
+	Source string `json:"source"`
+}
+
+// validate checks the required fields of the VM
+func (v VM) validate() error {
+	if v.Name == "" {
+		return errors.New("VM has no name")
+	}
+	for _, n := range v.NICs {
+		if n.Name == "" {
+			return fmt.Errorf("interface of VM %s has no name", v.Name)
+		}
+		switch n.Source {
+		case "", sync.IPSourceAgent, sync.IPSourceCloudInit, sync.IPSourceConfig:
+		default:
+			return fmt.Errorf("invalid source %q of VM %s interface %s", n.Source, v.Name, n.Name)
+		}
+		for _, ip := range n.IPs {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return fmt.Errorf("invalid IP address %q of VM %s interface %s, expected address/prefix", ip, v.Name, n.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// toVM converts the protocol VM
+func (v VM) toVM() sync.VM {
+	vm := sync.VM{
+		ID:           v.ID,
+		Name:         v.Name,
+		Description:  v.Description,
+		Memory:       v.Memory,
+		Diskspace:    v.Diskspace,
+		VCPUs:        v.VCPUs,
+		Status:       v.Status,
+		PowerState:   v.PowerState,
+		Serial:       v.Serial,
+		Type:         v.Type,
+		Role:         v.Role,
+		Tenant:       v.Tenant,
+		Tags:         v.Tags,
+		Metadata:     v.Metadata,
+		CustomFields: v.CustomFields,
+	}
+	if vm.ID == "" {
+		vm.ID = vm.Name
+	}
+	if vm.Type == "" {
+		vm.Type = sync.VMTypeVirtualMachine
+	}
+	if vm.Status == "" {
+		vm.Status = "active"
+	}
+	if v.Error != "" {
+		vm.Err = errors.New(v.Error)
+	}
+	for _, d := range v.Disks {
+		vm.Disks = append(vm.Disks, sync.Disk{ID: d.ID, Name: d.Name, Size: d.Size, Description: d.Description})
+	}
+	vm.Network = make([]sync.NIC, 0, len(v.NICs))
+	for _, n := range v.NICs {
+		nic := sync.NIC{
+			ID:          n.ID,
+			Name:        n.Name,
+			MAC:         strings.ToUpper(n.MAC),
+			Description: n.Description,
+			Type:        n.Type,
+			Network:     n.Network,
+			Switch:      n.Switch,
+			VLAN:        n.VLAN,
+			Tagged:      n.Tagged,
+			Enabled:     n.Enabled,
+		}
+		if nic.ID == "" {
+			nic.ID = nic.Name
+		}
+		source := n.Source
+		if source == "" {
+			source = sync.IPSourceConfig
+		}
+		for _, ip := range n.IPs {
+			nic.AddIP(ip, source)
+		}
+		vm.Network = append(vm.Network, nic)
+	}
+	return vm
+}
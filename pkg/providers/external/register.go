@@ -0,0 +1,28 @@
+package external
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "external",
+		Description: "an external program speaking PROTOCOL.md, PROVIDER_URL is its command line",
+		Settings: []providers.Setting{
+			{Name: "EXTERNAL_TIMEOUT", Default: strconv.Itoa(int(DefaultTimeout.Seconds())), Description: "the number of seconds the program may run for a single call"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			timeout, err := cfg.Int("EXTERNAL_TIMEOUT")
+			if err != nil {
+				return nil, err
+			}
+			return NewExternalProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithTimeout(time.Duration(timeout)*time.Second),
+			)
+		},
+	})
+}
@@ -0,0 +1,61 @@
+#!/bin/sh
+# provider.sh MODE answers the requests of the external provider tests in
+# the way selected by MODE.
+
+request=$(cat)
+method=$(printf '%s' "$request" | sed -n 's/.*"method":"\([A-Za-z]*\)".*/\1/p')
+
+case "$1" in
+valid)
+	case "$method" in
+	GetDatacenters)
+		echo '{"version": 1, "datacenters": [{"name": "East", "description": "East site"}]}'
+		;;
+	GetDcClusters)
+		case "$request" in
+		*'"datacenter_id":"East"'*) ;;
+		*) echo "unexpected request $request" >&2; exit 1 ;;
+		esac
+		echo '{"version": 1, "clusters": [{"id": "East/cl1", "name": "cl1"}]}'
+		;;
+	GetClusterVMs)
+		case "$request" in
+		*'"cluster_id":"East/cl1"'*) ;;
+		*) echo "unexpected request $request" >&2; exit 1 ;;
+		esac
+		cat <<'EOF'
+{
+  "version": 1,
+  "vms": [
+    {
+      "id": "vm-1", "name": "web1", "memory": 4096, "disk": 60, "vcpus": 2, "power_state": "Running",
+      "tags": ["web"], "metadata": {"owner": "web-team"},
+      "disks": [{"id": "disk0", "name": "disk0", "size": 64424509440}],
+      "nics": [{"name": "eth0", "mac": "00:15:5d:01:02:03", "ips": ["10.0.0.5/24"], "source": "agent", "vlan": 10}]
+    },
+    {"name": "db1", "error": "details not available"}
+  ]
+}
+EOF
+		;;
+	esac
+	;;
+version)
+	echo '{"version": 2, "datacenters": []}'
+	;;
+unknown)
+	echo '{"version": 1, "datacenters": [{"name": "East", "site": "east"}]}'
+	;;
+fail)
+	echo "connecting to the hypervisor" >&2
+	echo "hypervisor unreachable" >&2
+	exit 3
+	;;
+sleep)
+	exec sleep 30
+	;;
+env)
+	printf '{"version": 1, "datacenters": [{"name": "%s", "description": "%s"}]}\n' \
+		"$NETBOXVMSYNC_PROVIDER_TOKEN $NETBOXVMSYNC_SITE" "$NETBOX_TOKEN$PROVIDER_TOKEN"
+	;;
+esac
@@ -0,0 +1,206 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// metaPrefix is the prefix of CSV columns holding VM metadata, eg.
+// meta_owner
+const metaPrefix = "meta_"
+
+// listSeparator separates the values of the tags and ips columns
+const listSeparator = ";"
+
+// csvColumns are the columns of CSV inventory files
+var csvColumns = map[string]bool{
+	"datacenter": true, "cluster": true, "id": true, "name": true, "description": true,
+	"type": true, "status": true, "power_state": true, "vcpus": true, "memory": true, "disk": true,
+	"serial": true, "role": true, "tenant": true, "tags": true,
+	"nic": true, "mac": true, "ips": true, "network": true, "vlan": true, "enabled": true,
+}
+
+// requiredColumns must be present in CSV inventory files
+var requiredColumns = []string{"datacenter", "cluster", "name"}
+
+// parseCSV parses a CSV inventory file.  The first row names the columns.
+// Every row is a VM, or an additional interface of the VM of a previous row
+// with the same datacenter, cluster and ID or name.
+func parseCSV(file string, data []byte) (inventoryFile, error) {
+	inv := inventoryFile{}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return inv, fmt.Errorf("%s: %w", file, err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		if !csvColumns[header[i]] && !strings.HasPrefix(header[i], metaPrefix) {
+			return inv, fmt.Errorf("%s:1: unknown column %q", file, header[i])
+		}
+	}
+	for _, column := range requiredColumns {
+		if !contains(header, column) {
+			return inv, fmt.Errorf("%s:1: missing column %q", file, column)
+		}
+	}
+	errs := make([]error, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				err = fmt.Errorf("%s:%d:%d: %w", file, parseErr.Line, parseErr.Column, parseErr.Err)
+			} else {
+				err = fmt.Errorf("%s: %w", file, err)
+			}
+			errs = append(errs, err)
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		row := make(map[string]string)
+		for i, value := range record {
+			row[header[i]] = strings.TrimSpace(value)
+		}
+		if err = addRow(&inv, row, line); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", file, line, err))
+		}
+	}
+	return inv, errors.Join(errs...)
+}
+
+// addRow adds the VM of the row to the inventory, or its interface to the
+// VM already added
+func addRow(inv *inventoryFile, row map[string]string, line int) error {
+	dc := findDatacenter(inv, row["datacenter"], line)
+	cl := findCluster(dc, row["cluster"], line)
+	var vm *vmEntry
+	for i := range cl.VMs {
+		if cl.VMs[i].Name == row["name"] && cl.VMs[i].ID == row["id"] {
+			vm = &cl.VMs[i]
+		}
+	}
+	if vm == nil {
+		entry, err := rowVM(row, line)
+		if err != nil {
+			return err
+		}
+		cl.VMs = append(cl.VMs, entry)
+		vm = &cl.VMs[len(cl.VMs)-1]
+	}
+	if row["nic"] == "" && row["mac"] == "" && row["ips"] == "" {
+		return nil
+	}
+	nic := nicEntry{Name: row["nic"], MAC: row["mac"], Network: row["network"], IPs: split(row["ips"]), line: line}
+	if row["vlan"] != "" {
+		vlan, err := strconv.Atoi(row["vlan"])
+		if err != nil {
+			return fmt.Errorf("invalid vlan %q", row["vlan"])
+		}
+		nic.VLAN = vlan
+	}
+	if row["enabled"] != "" {
+		enabled, err := strconv.ParseBool(row["enabled"])
+		if err != nil {
+			return fmt.Errorf("invalid enabled %q", row["enabled"])
+		}
+		nic.Enabled = &enabled
+	}
+	vm.NICs = append(vm.NICs, nic)
+	return nil
+}
+
+// rowVM converts the VM columns of the row
+func rowVM(row map[string]string, line int) (vmEntry, error) {
+	vm := vmEntry{
+		ID:          row["id"],
+		Name:        row["name"],
+		Description: row["description"],
+		Type:        row["type"],
+		Status:      row["status"],
+		PowerState:  row["power_state"],
+		Serial:      row["serial"],
+		Role:        row["role"],
+		Tenant:      row["tenant"],
+		Tags:        split(row["tags"]),
+		line:        line,
+	}
+	if row["vcpus"] != "" {
+		vcpus, err := strconv.ParseFloat(row["vcpus"], 32)
+		if err != nil {
+			return vm, fmt.Errorf("invalid vcpus %q", row["vcpus"])
+		}
+		vm.VCPUs = float32(vcpus)
+	}
+	for _, column := range []struct {
+		name  string
+		value *int
+	}{{"memory", &vm.Memory}, {"disk", &vm.Disk}} {
+		if row[column.name] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(row[column.name])
+		if err != nil {
+			return vm, fmt.Errorf("invalid %s %q", column.name, row[column.name])
+		}
+		*column.value = n
+	}
+	for column, value := range row {
+		if key, ok := strings.CutPrefix(column, metaPrefix); ok && value != "" {
+			if vm.Metadata == nil {
+				vm.Metadata = make(map[string]string)
+			}
+			vm.Metadata[key] = value
+		}
+	}
+	return vm, nil
+}
+
+func findDatacenter(inv *inventoryFile, name string, line int) *datacenterEntry {
+	for i := range inv.Datacenters {
+		if inv.Datacenters[i].Name == name {
+			return &inv.Datacenters[i]
+		}
+	}
+	inv.Datacenters = append(inv.Datacenters, datacenterEntry{Name: name, line: line})
+	return &inv.Datacenters[len(inv.Datacenters)-1]
+}
+
+func findCluster(dc *datacenterEntry, name string, line int) *clusterEntry {
+	for i := range dc.Clusters {
+		if dc.Clusters[i].Name == name {
+			return &dc.Clusters[i]
+		}
+	}
+	dc.Clusters = append(dc.Clusters, clusterEntry{Name: name, line: line})
+	return &dc.Clusters[len(dc.Clusters)-1]
+}
+
+// split splits a list column, ignoring empty values
+func split(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, listSeparator) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+// Package inventory syncs the VMs declared in static inventory files, for
+// environments that cannot be reached through an API.  Files are YAML, JSON
+// or CSV and may be split across a directory.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*InventoryProvider)(nil)
+
+// parsers are the inventory file parsers by file extension
+var parsers = map[string]func(file string, data []byte) (inventoryFile, error){
+	".yaml": parseYAML,
+	".yml":  parseYAML,
+	".json": parseYAML,
+	".csv":  parseCSV,
+}
+
+type datacenter struct {
+	sync.Datacenter
+	clusters []*cluster
+}
+
+type cluster struct {
+	sync.Cluster
+	vms []sync.VM
+	// origins holds the file:line of each VM ID to report duplicates
+	origins map[string]string
+}
+
+type InventoryProvider struct {
+	log         pkg.Logger
+	datacenters []*datacenter
+}
+
+// NewInventoryProvider creates a new VM sync provider for the inventory
+// file or directory of files at baseURL, a path or file:// URL.  The files
+// are read and validated once; all errors are returned with their line.
+func NewInventoryProvider(baseURL string, username string, password string, logger pkg.Logger) (*InventoryProvider, error) {
+	inv := &InventoryProvider{log: logger}
+	if log, ok := logger.(*slog.Logger); ok {
+		inv.log = log.With("provider", inv.GetName())
+	}
+	path := strings.TrimPrefix(baseURL, "file://")
+	files, err := inventoryFiles(path)
+	if err != nil {
+		return inv, err
+	}
+	errs := make([]error, 0)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Files with parse errors are still validated to report all errors
+		parsed, parseErr := parsers[strings.ToLower(filepath.Ext(file))](file, data)
+		validateErr := parsed.validate(file)
+		if parseErr != nil || validateErr != nil {
+			errs = append(errs, parseErr, validateErr)
+			continue
+		}
+		errs = append(errs, inv.add(file, parsed))
+	}
+	if err = errors.Join(errs...); err != nil {
+		return inv, err
+	}
+	inv.log.Info("read inventory", "path", path, "files", len(files))
+	return inv, nil
+}
+
+// inventoryFiles returns the file, or the inventory files of the directory
+func inventoryFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if _, ok := parsers[strings.ToLower(filepath.Ext(path))]; !ok {
+			return nil, fmt.Errorf("%s: unsupported inventory file type, expected .yaml, .yml, .json or .csv", path)
+		}
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if _, ok := parsers[strings.ToLower(filepath.Ext(entry.Name()))]; ok && !entry.IsDir() {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no inventory files found", path)
+	}
+	return files, nil
+}
+
+// add merges the datacenters, clusters and VMs of the file into the
+// inventory
+func (inv *InventoryProvider) add(file string, parsed inventoryFile) error {
+	errs := make([]error, 0)
+	for _, dcEntry := range parsed.Datacenters {
+		dc := inv.datacenter(dcEntry)
+		for _, clEntry := range dcEntry.Clusters {
+			cl := dc.cluster(clEntry)
+			for _, vmEntry := range clEntry.VMs {
+				origin := fmt.Sprintf("%s:%d", file, vmEntry.line)
+				if first, ok := cl.origins[vmEntry.id()]; ok {
+					errs = append(errs, fmt.Errorf("%s: duplicate VM %q, first defined at %s", origin, vmEntry.id(), first))
+					continue
+				}
+				cl.origins[vmEntry.id()] = origin
+				cl.vms = append(cl.vms, vmEntry.toVM())
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// datacenter returns the datacenter of the entry, adding it if needed
+func (inv *InventoryProvider) datacenter(entry datacenterEntry) *datacenter {
+	for _, dc := range inv.datacenters {
+		if dc.Name == entry.Name {
+			if dc.Description == "" {
+				dc.Description = entry.Description
+			}
+			return dc
+		}
+	}
+	dc := &datacenter{Datacenter: sync.Datacenter{ID: entry.Name, Name: entry.Name, Description: entry.Description}}
+	inv.datacenters = append(inv.datacenters, dc)
+	return dc
+}
+
+// cluster returns the cluster of the entry, adding it if needed
+func (dc *datacenter) cluster(entry clusterEntry) *cluster {
+	for _, cl := range dc.clusters {
+		if cl.Name == entry.Name {
+			if cl.Description == "" {
+				cl.Description = entry.Description
+			}
+			return cl
+		}
+	}
+	cl := &cluster{
+		Cluster: sync.Cluster{ID: dc.ID + "/" + entry.Name, Name: entry.Name, Description: entry.Description},
+		origins: make(map[string]string),
+	}
+	dc.clusters = append(dc.clusters, cl)
+	return cl
+}
+
+func (inv *InventoryProvider) GetName() string {
+	return "inventory"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider
+func (inv *InventoryProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	dcs := make([]sync.Datacenter, 0, len(inv.datacenters))
+	for _, dc := range inv.datacenters {
+		dcs = append(dcs, dc.Datacenter)
+	}
+	return dcs, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID
+func (inv *InventoryProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	for _, dc := range inv.datacenters {
+		if dc.ID != datacenterID {
+			continue
+		}
+		clusters := make([]sync.Cluster, 0, len(dc.clusters))
+		for _, cl := range dc.clusters {
+			clusters = append(clusters, cl.Cluster)
+		}
+		return clusters, nil
+	}
+	return nil, fmt.Errorf("unknown datacenter %s", datacenterID)
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (inv *InventoryProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	for _, dc := range inv.datacenters {
+		for _, cl := range dc.clusters {
+			if cl.ID == clusterID {
+				return cl.vms, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown cluster %s", clusterID)
+}
@@ -0,0 +1,226 @@
+package inventory
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInventoryErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "yaml syntax",
+			file: "bad.yaml",
+			content: `datacenters:
+  - name: dc1
+    clusters:
+      - name: cl1
+        vms: [fw1
+`,
+			expected: []string{"bad.yaml:5: did not find expected ',' or ']'"},
+		},
+		{
+			name: "yaml unknown field and type",
+			file: "bad.yaml",
+			content: `datacenters:
+  - name: dc1
+    clusters:
+      - name: cl1
+        vms:
+          - name: fw1
+            memroy: 4096
+          - name: fw2
+            memory: lots
+`,
+			expected: []string{
+				"bad.yaml:7: field memroy not found",
+				"bad.yaml:9: cannot unmarshal !!str `lots` into int",
+			},
+		},
+		{
+			name: "yaml validation",
+			file: "bad.yml",
+			content: `datacenters:
+  - name: dc1
+    clusters:
+      - name: cl1
+        vms:
+          - name: fw1
+            status: running
+            type: lxc
+          - description: no name
+          - name: fw1
+            vcpus: -1
+            disks:
+              - {name: sda, size: -5}
+            nics:
+              - {name: eth0, mac: "00:11:22:33:44", ips: [10.0.0.300/24]}
+              - {mac: "00:11:22:33:44:55", vlan: 5000}
+      - vms: []
+  - clusters: []
+`,
+			expected: []string{
+				`bad.yml:6: invalid type "lxc", expected vm or container`,
+				`bad.yml:6: invalid status "running", expected one of: active, offline, planned, staged, failed, decommissioning, paused`,
+				"bad.yml:9: VM has no name",
+				`bad.yml:10: duplicate VM "fw1", first defined on line 6`,
+				"bad.yml:10: vcpus, memory and disk must not be negative",
+				"bad.yml:13: disk size must not be negative",
+				`bad.yml:15: invalid MAC address "00:11:22:33:44"`,
+				`bad.yml:15: invalid IP address "10.0.0.300/24"`,
+				"bad.yml:16: interface of VM fw1 has no name",
+				"bad.yml:16: invalid VLAN 5000",
+				"bad.yml:17: cluster has no name",
+				"bad.yml:18: datacenter has no name",
+			},
+		},
+		{
+			name: "json syntax",
+			file: "bad.json",
+			content: `{
+  "datacenters": [
+    {"name": "dc1", "clusters": [
+      {"name": "cl1", "vms": [{"name": "fw1"} {"name": "fw2"}]}
+    ]}
+  ]
+}
+`,
+			expected: []string{"bad.json:4: did not find expected ',' or ']'"},
+		},
+		{
+			name: "json validation",
+			file: "bad.json",
+			content: `{
+  "datacenters": [
+    {
+      "name": "dc1",
+      "clusters": [
+        {
+          "name": "cl1",
+          "vms": [
+            {"name": "fw1", "status": "Active"},
+            {"name": "fw2", "nics": [{"name": "eth0", "ips": ["fe80::zz"]}]}
+          ]
+        }
+      ]
+    }
+  ]
+}
+`,
+			expected: []string{
+				`bad.json:9: invalid status "Active", expected one of: active, offline, planned, staged, failed, decommissioning, paused`,
+				`bad.json:10: invalid IP address "fe80::zz"`,
+			},
+		},
+		{
+			name:     "csv unknown column",
+			file:     "bad.csv",
+			content:  "datacenter,cluster,name,memroy\ndc1,cl1,fw1,4096\n",
+			expected: []string{`bad.csv:1: unknown column "memroy"`},
+		},
+		{
+			name:     "csv missing column",
+			file:     "bad.csv",
+			content:  "datacenter,name\ndc1,fw1\n",
+			expected: []string{`bad.csv:1: missing column "cluster"`},
+		},
+		{
+			name: "csv rows",
+			file: "bad.csv",
+			content: `datacenter,cluster,name,status,memory,nic,mac,ips,vlan
+dc1,cl1,fw1,active,4096,eth0,00:11:22:33:44:55,10.0.0.1/24,10
+dc1,cl1,fw2,active,lots,,,,
+dc1,cl1,fw3,stopped,1024,,,,
+dc1,cl1,fw1,,,eth1,00:11:22:33:44:zz,10.0.1.1/24,
+dc1,cl1,fw4,,,eth0,,,ten
+`,
+			expected: []string{
+				`bad.csv:3: invalid memory "lots"`,
+				`bad.csv:6: invalid vlan "ten"`,
+				`bad.csv:5: invalid MAC address "00:11:22:33:44:zz"`,
+				`bad.csv:4: invalid status "stopped", expected one of: active, offline, planned, staged, failed, decommissioning, paused`,
+			},
+		},
+		{
+			name: "csv quotes",
+			file: "bad.csv",
+			content: `datacenter,cluster,name,description
+dc1,cl1,fw1,"firewall"
+dc1,cl1,fw2,"unterminated
+`,
+			expected: []string{`bad.csv:3:27: extraneous or missing " in quoted-field`},
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, test.file), []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := NewInventoryProvider(dir, "", "", logger)
+			if err == nil {
+				t.Fatalf("expected errors %q", test.expected)
+			}
+			errs := strings.Split(strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), ""), "\n")
+			if !reflect.DeepEqual(errs, test.expected) {
+				t.Errorf("errors\n%s\nexpected\n%s", strings.Join(errs, "\n"), strings.Join(test.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestInventoryFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": `datacenters:
+  - name: dc1
+    clusters:
+      - name: cl1
+        vms:
+          - name: fw1
+            status: planned
+            nics: [{name: eth0, ips: [10.0.0.1]}]
+`,
+		"b.csv": "datacenter,cluster,name,memory,meta_owner\ndc1,cl1,fw2,2048,netops\n",
+		"c.csv": "datacenter,cluster,name\ndc1,cl1,fw1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := NewInventoryProvider(dir, "", "", logger)
+	expected := `c.csv:2: duplicate VM "fw1", first defined at a.yaml:6`
+	if err == nil || strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), "") != expected {
+		t.Fatalf("error %v, expected %s", err, expected)
+	}
+
+	if err = os.Remove(filepath.Join(dir, "c.csv")); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := NewInventoryProvider("file://"+dir, "", "", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vms, err := inv.GetClusterVMs("dc1/cl1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 2 || vms[0].Status != "planned" || vms[1].Status != "active" || vms[1].Memory != 2048 || vms[1].Metadata["owner"] != "netops" {
+		t.Fatalf("VMs %+v", vms)
+	}
+	if ips := vms[0].Network[0].IP; !reflect.DeepEqual(ips, []string{"10.0.0.1/32"}) {
+		t.Errorf("fw1 IPs %v, expected the host address", ips)
+	}
+}
@@ -0,0 +1,16 @@
+package inventory
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "inventory",
+		Description: "static YAML, JSON or CSV inventory, PROVIDER_URL is a file or directory",
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewInventoryProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger)
+		},
+	})
+}
@@ -0,0 +1,205 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+const gb = 1073741824
+
+// statuses are the Netbox VM statuses
+var statuses = []string{"active", "offline", "planned", "staged", "failed", "decommissioning", "paused"}
+
+// inventoryFile is the content of an inventory file.  YAML and JSON files
+// hold it as is, CSV files are converted into it.
+type inventoryFile struct {
+	Datacenters []datacenterEntry `yaml:"datacenters"`
+}
+
+type datacenterEntry struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Clusters    []clusterEntry `yaml:"clusters"`
+	line        int
+}
+
+type clusterEntry struct {
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description"`
+	VMs         []vmEntry `yaml:"vms"`
+	line        int
+}
+
+type vmEntry struct {
+	ID          string  `yaml:"id"`
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Type        string  `yaml:"type"`
+	Status      string  `yaml:"status"`
+	PowerState  string  `yaml:"power_state"`
+	VCPUs       float32 `yaml:"vcpus"`
+	// Memory is in MB
+	Memory int `yaml:"memory"`
+	// Disk is the total disk space in GB, the sum of Disks if not set
+	Disk     int               `yaml:"disk"`
+	Disks    []diskEntry       `yaml:"disks"`
+	Serial   string            `yaml:"serial"`
+	Role     string            `yaml:"role"`
+	Tenant   string            `yaml:"tenant"`
+	Tags     []string          `yaml:"tags"`
+	Metadata map[string]string `yaml:"metadata"`
+	NICs     []nicEntry        `yaml:"nics"`
+	line     int
+}
+
+type diskEntry struct {
+	Name string `yaml:"name"`
+	// Size is in GB
+	Size        int64  `yaml:"size"`
+	Description string `yaml:"description"`
+	line        int
+}
+
+type nicEntry struct {
+	Name        string   `yaml:"name"`
+	MAC         string   `yaml:"mac"`
+	Description string   `yaml:"description"`
+	IPs         []string `yaml:"ips"`
+	Network     string   `yaml:"network"`
+	VLAN        int      `yaml:"vlan"`
+	Enabled     *bool    `yaml:"enabled"`
+	line        int
+}
+
+// validate checks the entries of the file, returning all errors found
+// along with their line numbers
+func (f inventoryFile) validate(file string) error {
+	errs := make([]error, 0)
+	fail := func(line int, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s:%d: %s", file, line, fmt.Sprintf(format, args...)))
+	}
+	for _, dc := range f.Datacenters {
+		if strings.TrimSpace(dc.Name) == "" {
+			fail(dc.line, "datacenter has no name")
+		}
+		for _, cl := range dc.Clusters {
+			if strings.TrimSpace(cl.Name) == "" {
+				fail(cl.line, "cluster has no name")
+			}
+			ids := make(map[string]int)
+			for _, vm := range cl.VMs {
+				if strings.TrimSpace(vm.Name) == "" {
+					fail(vm.line, "VM has no name")
+					continue
+				}
+				if line, ok := ids[vm.id()]; ok {
+					fail(vm.line, "duplicate VM %q, first defined on line %d", vm.id(), line)
+				}
+				ids[vm.id()] = vm.line
+				if vm.Type != "" && vm.Type != sync.VMTypeVirtualMachine && vm.Type != sync.VMTypeContainer {
+					fail(vm.line, "invalid type %q, expected %s or %s", vm.Type, sync.VMTypeVirtualMachine, sync.VMTypeContainer)
+				}
+				if vm.Status != "" && !contains(statuses, vm.Status) {
+					fail(vm.line, "invalid status %q, expected one of: %s", vm.Status, strings.Join(statuses, ", "))
+				}
+				if vm.VCPUs < 0 || vm.Memory < 0 || vm.Disk < 0 {
+					fail(vm.line, "vcpus, memory and disk must not be negative")
+				}
+				for _, disk := range vm.Disks {
+					if disk.Size < 0 {
+						fail(disk.line, "disk size must not be negative")
+					}
+				}
+				for _, nic := range vm.NICs {
+					if strings.TrimSpace(nic.Name) == "" {
+						fail(nic.line, "interface of VM %s has no name", vm.Name)
+					}
+					if nic.MAC != "" {
+						if _, err := net.ParseMAC(nic.MAC); err != nil {
+							fail(nic.line, "invalid MAC address %q", nic.MAC)
+						}
+					}
+					for _, ip := range nic.IPs {
+						if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+							fail(nic.line, "invalid IP address %q", ip)
+						}
+					}
+					if nic.VLAN < 0 || nic.VLAN > 4094 {
+						fail(nic.line, "invalid VLAN %d", nic.VLAN)
+					}
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// id returns the ID of the VM, which is its name if not set
+func (v vmEntry) id() string {
+	if v.ID != "" {
+		return v.ID
+	}
+	return v.Name
+}
+
+// toVM converts the entry into a VM
+func (v vmEntry) toVM() sync.VM {
+	vm := sync.VM{
+		ID:          v.id(),
+		Name:        v.Name,
+		Description: v.Description,
+		Type:        v.Type,
+		Status:      v.Status,
+		PowerState:  v.PowerState,
+		VCPUs:       v.VCPUs,
+		Memory:      v.Memory,
+		Diskspace:   v.Disk,
+		Serial:      v.Serial,
+		Role:        v.Role,
+		Tenant:      v.Tenant,
+		Tags:        v.Tags,
+		Metadata:    v.Metadata,
+	}
+	if vm.Type == "" {
+		vm.Type = sync.VMTypeVirtualMachine
+	}
+	if vm.Status == "" {
+		vm.Status = "active"
+	}
+	var diskspace int64
+	for _, disk := range v.Disks {
+		vm.Disks = append(vm.Disks, sync.Disk{ID: disk.Name, Name: disk.Name, Size: disk.Size * gb, Description: disk.Description})
+		diskspace += disk.Size
+	}
+	if vm.Diskspace == 0 {
+		vm.Diskspace = int(diskspace)
+	}
+	vm.Network = make([]sync.NIC, 0, len(v.NICs))
+	for _, n := range v.NICs {
+		nic := sync.NIC{ID: n.Name, Name: n.Name, MAC: strings.ToUpper(n.MAC), Description: n.Description}
+		nic.Network = n.Network
+		nic.VLAN = n.VLAN
+		nic.Enabled = n.Enabled
+		for _, ip := range n.IPs {
+			nic.AddIP(hostAddress(ip), sync.IPSourceConfig)
+		}
+		vm.Network = append(vm.Network, nic)
+	}
+	return vm
+}
+
+// hostAddress returns the address with its prefix length, adding /32 or
+// /128 to addresses without one
+func hostAddress(ip string) string {
+	if strings.Contains(ip, "/") {
+		return ip
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
@@ -0,0 +1,142 @@
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLine matches the line number of yaml errors
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// parserProblems are the syntax errors of the yaml parser, which reports
+// their line counting from 0 unlike those of the scanner
+var parserProblems = map[string]bool{
+	"did not find expected ',' or ']'":       true,
+	"did not find expected ',' or '}'":       true,
+	"did not find expected '-' indicator":    true,
+	"did not find expected <document start>": true,
+	"did not find expected <stream-start>":   true,
+	"did not find expected key":              true,
+	"did not find expected node content":     true,
+	"found duplicate %TAG directive":         true,
+	"found duplicate %YAML directive":        true,
+	"found incompatible YAML document":       true,
+	"found undefined tag handle":             true,
+}
+
+// parseYAML parses a YAML or JSON inventory file.  JSON is parsed as YAML,
+// which it is a subset of, so errors of both have line numbers.
+func parseYAML(file string, data []byte) (inventoryFile, error) {
+	inv := inventoryFile{}
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return inv, yamlError(file, err)
+	}
+	// Type errors leave the other entries decoded, so they are returned
+	// along with the entries to be validated
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&inv)
+	if err == io.EOF {
+		err = nil
+	} else if err != nil {
+		err = yamlError(file, err)
+	}
+	setLines(&root, &inv)
+	return inv, err
+}
+
+// yamlError converts the errors of the yaml decoder into file:line errors
+func yamlError(file string, err error) error {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	syntax := !errors.As(err, &typeErr)
+	if !syntax {
+		messages = typeErr.Errors
+	}
+	errs := make([]error, 0, len(messages))
+	for _, msg := range messages {
+		// Hide the Go type names of unknown field errors
+		msg, _, _ = strings.Cut(msg, " in type ")
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			problem := msg[len(m[0]):]
+			if syntax && parserProblems[problem] {
+				line++
+			}
+			errs = append(errs, fmt.Errorf("%s:%d: %s", file, line, problem))
+		} else {
+			errs = append(errs, fmt.Errorf("%s: %s", file, strings.TrimPrefix(msg, "yaml: ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setLines sets the line numbers of the entries from the parsed document
+func setLines(root *yaml.Node, inv *inventoryFile) {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return
+	}
+	dcNodes := items(value(root.Content[0], "datacenters"))
+	for i := range inv.Datacenters {
+		dc := &inv.Datacenters[i]
+		if i >= len(dcNodes) {
+			return
+		}
+		dc.line = dcNodes[i].Line
+		clusterNodes := items(value(dcNodes[i], "clusters"))
+		for j := range dc.Clusters {
+			if j >= len(clusterNodes) {
+				break
+			}
+			cl := &dc.Clusters[j]
+			cl.line = clusterNodes[j].Line
+			vmNodes := items(value(clusterNodes[j], "vms"))
+			for k := range cl.VMs {
+				if k >= len(vmNodes) {
+					break
+				}
+				vm := &cl.VMs[k]
+				vm.line = vmNodes[k].Line
+				for l, node := range items(value(vmNodes[k], "disks")) {
+					if l < len(vm.Disks) {
+						vm.Disks[l].line = node.Line
+					}
+				}
+				for l, node := range items(value(vmNodes[k], "nics")) {
+					if l < len(vm.NICs) {
+						vm.NICs[l].line = node.Line
+					}
+				}
+			}
+		}
+	}
+}
+
+// value returns the value of the key of a mapping node, or nil
+func value(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// items returns the items of a sequence node
+func items(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
@@ -0,0 +1,290 @@
+package kubevirt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceAccountDir holds the token and CA of the pod's service account
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// listLimit is the number of objects requested per page
+const listLimit = 500
+
+// client is a minimal Kubernetes API client
+type client struct {
+	server string
+	// clusterName is the kubeconfig cluster name, or the API server host
+	clusterName string
+	token       string
+	username    string
+	password    string
+	http        *http.Client
+}
+
+// kubeconfig is the part of a kubeconfig file used by the client
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Username              string `yaml:"username"`
+			Password              string `yaml:"password"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// newClient creates a client for the location, which is the URL of the API
+// server, the path of a kubeconfig file, or empty to use the in-cluster
+// service account or the default kubeconfig.  contextName selects the
+// kubeconfig context and token is the bearer token of an API server URL.
+// The certificate of an API server URL is verified with the CA certificates
+// of caFile, or the system ones, unless insecure is set.
+func newClient(location string, contextName string, token string, caFile string, insecure bool) (*client, error) {
+	switch {
+	case strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://"):
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		c := &client{server: strings.TrimSuffix(location, "/"), clusterName: u.Hostname(), token: token}
+		tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+		if caFile != "" {
+			ca, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("certificate authority: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("certificate authority: no certificates in %s", caFile)
+			}
+		}
+		c.http = httpClient(tlsConfig)
+		return c, nil
+	case location == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		return inClusterClient()
+	case location == "":
+		location = os.Getenv("KUBECONFIG")
+		if location == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			location = filepath.Join(home, ".kube", "config")
+		}
+		// KUBECONFIG may list several files, the first one is used
+		location = filepath.SplitList(location)[0]
+	}
+	c, err := kubeconfigClient(location, contextName)
+	if err == nil && token != "" {
+		c.token = token
+	}
+	return c, err
+}
+
+// inClusterClient creates a client with the service account of the pod
+func inClusterClient() (*client, error) {
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt")); err == nil {
+		pool.AppendCertsFromPEM(ca)
+	}
+	host := net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+	c := &client{server: "https://" + host, clusterName: "kubernetes", token: strings.TrimSpace(string(token))}
+	c.http = httpClient(&tls.Config{RootCAs: pool})
+	return c, nil
+}
+
+// kubeconfigClient creates a client from the context of the kubeconfig
+// file, or its current context if contextName is empty
+func kubeconfigClient(path string, contextName string) (*client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := kubeconfig{}
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if contextName == "" {
+		contextName = cfg.CurrentContext
+	}
+	var clusterName, userName string
+	found := false
+	for _, ctx := range cfg.Contexts {
+		if ctx.Name == contextName {
+			clusterName, userName, found = ctx.Context.Cluster, ctx.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: context %q not found", path, contextName)
+	}
+	c := &client{clusterName: clusterName}
+	tlsConfig := &tls.Config{}
+	// Relative file names are relative to the kubeconfig
+	dir := filepath.Dir(path)
+	for _, cl := range cfg.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		c.server = strings.TrimSuffix(cl.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+		ca, err := inlineOrFile(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority, dir)
+		if err != nil {
+			return nil, fmt.Errorf("certificate authority: %w", err)
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+		}
+	}
+	if c.server == "" {
+		return nil, fmt.Errorf("%s: cluster %q has no server", path, clusterName)
+	}
+	for _, u := range cfg.Users {
+		if u.Name != userName {
+			continue
+		}
+		c.token, c.username, c.password = u.User.Token, u.User.Username, u.User.Password
+		if c.token == "" && u.User.TokenFile != "" {
+			token, err := os.ReadFile(resolve(u.User.TokenFile, dir))
+			if err != nil {
+				return nil, err
+			}
+			c.token = strings.TrimSpace(string(token))
+		}
+		cert, err := inlineOrFile(u.User.ClientCertificateData, u.User.ClientCertificate, dir)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		key, err := inlineOrFile(u.User.ClientKeyData, u.User.ClientKey, dir)
+		if err != nil {
+			return nil, fmt.Errorf("client key: %w", err)
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	c.http = httpClient(tlsConfig)
+	return c, nil
+}
+
+// inlineOrFile returns the base64 decoded data, or the content of the file
+func inlineOrFile(data string, file string, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(resolve(file, dir))
+	}
+	return nil, nil
+}
+
+// resolve returns the file name relative to dir unless it is absolute
+func resolve(file string, dir string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+func httpClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+// get decodes the response of the API path into result
+func (c *client) get(ctx context.Context, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// list returns all objects of the list path, following the continue token
+// of paginated responses
+func list[T any](ctx context.Context, c *client, path string) ([]T, error) {
+	items := make([]T, 0)
+	next := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(listLimit)}}
+		if next != "" {
+			query.Set("continue", next)
+		}
+		page := struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Items []T `json:"items"`
+		}{}
+		if err := c.get(ctx, path+"?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.Metadata.Continue == "" {
+			return items, nil
+		}
+		next = page.Metadata.Continue
+	}
+}
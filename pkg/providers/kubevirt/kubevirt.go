@@ -0,0 +1,393 @@
+// Package kubevirt syncs the VirtualMachines of a Kubernetes cluster
+// running KubeVirt.  The Kubernetes cluster is synced as a Netbox cluster.
+package kubevirt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*KubevirtProvider)(nil)
+
+const mb = 1048576
+const gb = 1073741824
+
+// Name formats decide how VM names are qualified with their namespace, so
+// same-named VMs of different namespaces do not collide in the cluster
+const (
+	// NameAuto is NameBare when METADATA_RULES map namespaces to tenants,
+	// which keeps the VMs apart in Netbox, and NameQualified otherwise
+	NameAuto = "auto"
+	// NameQualified names VMs namespace/name
+	NameQualified = "namespace/name"
+	// NameDotted names VMs name.namespace
+	NameDotted = "name.namespace"
+	// NameBare names VMs after their metadata.name only
+	NameBare = "name"
+)
+
+// Option configures the KubeVirt provider
+type Option func(*KubevirtProvider)
+
+// WithClusterName sets the name of the Netbox cluster instead of the
+// kubeconfig cluster name
+func WithClusterName(name string) Option {
+	return func(k *KubevirtProvider) {
+		if name != "" {
+			k.clusterName = name
+		}
+	}
+}
+
+// WithNameFormat sets how VM names are qualified with their namespace.
+// NameAuto must be resolved with ResolveNameFormat first.
+func WithNameFormat(format string) Option {
+	return func(k *KubevirtProvider) {
+		if format != "" {
+			k.nameFormat = strings.ToLower(format)
+		}
+	}
+}
+
+// WithCAFile sets the CA certificates verifying an API server URL
+func WithCAFile(file string) Option {
+	return func(k *KubevirtProvider) {
+		k.caFile = file
+	}
+}
+
+// WithInsecure disables the certificate verification of an API server URL
+func WithInsecure(insecure bool) Option {
+	return func(k *KubevirtProvider) {
+		k.insecure = insecure
+	}
+}
+
+// WithNamespaces limits the sync to the VMs of the namespaces
+func WithNamespaces(namespaces []string) Option {
+	return func(k *KubevirtProvider) {
+		k.namespaces = namespaces
+	}
+}
+
+type KubevirtProvider struct {
+	client      *client
+	log         pkg.Logger
+	clusterName string
+	namespaces  []string
+	nameFormat  string
+	caFile      string
+	insecure    bool
+}
+
+// ResolveNameFormat returns the name format of NameAuto for the metadata
+// rules, or the format itself
+func ResolveNameFormat(format string, rules []sync.MetadataRule) string {
+	if !strings.EqualFold(format, NameAuto) {
+		return format
+	}
+	for _, rule := range rules {
+		if rule.Key == sync.MetaNamespace && rule.Target == sync.TargetTenant {
+			return NameBare
+		}
+	}
+	return NameQualified
+}
+
+// NewKubevirtProvider creates a new VM sync provider for KubeVirt.  baseURL
+// is the path of a kubeconfig file or the URL of the API server; when empty
+// the in-cluster service account or the default kubeconfig is used.
+// username selects the kubeconfig context and password is a bearer token.
+func NewKubevirtProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*KubevirtProvider, error) {
+	kv := &KubevirtProvider{log: logger, nameFormat: NameQualified}
+	if log, ok := logger.(*slog.Logger); ok {
+		kv.log = log.With("provider", kv.GetName())
+	}
+	for _, opt := range opts {
+		opt(kv)
+	}
+	if kv.nameFormat != NameQualified && kv.nameFormat != NameDotted && kv.nameFormat != NameBare {
+		return kv, fmt.Errorf("invalid name format %q, expected %s, %s or %s", kv.nameFormat, NameQualified, NameDotted, NameBare)
+	}
+	c, err := newClient(baseURL, username, password, kv.caFile, kv.insecure)
+	if err != nil {
+		return kv, err
+	}
+	kv.client = c
+	if kv.clusterName == "" {
+		kv.clusterName = c.clusterName
+	}
+	if kv.clusterName == "" {
+		kv.clusterName = "kubernetes"
+	}
+	kv.log.Info("Connecting...", "url", c.server)
+	version := struct {
+		GitVersion string `json:"gitVersion"`
+	}{}
+	if err = c.get(context.Background(), "/version", &version); err != nil {
+		return kv, err
+	}
+	kv.log.Info("connected to kubernetes", "cluster", kv.clusterName, "version", version.GitVersion)
+	return kv, nil
+}
+
+func (kv *KubevirtProvider) GetName() string {
+	return "KubeVirt"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider
+func (kv *KubevirtProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	dc := sync.Datacenter{ID: kv.GetName(), Name: kv.GetName(), Description: "KubeVirt clusters"}
+	return []sync.Datacenter{dc}, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID.  The
+// Kubernetes cluster is the only cluster.
+func (kv *KubevirtProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	return []sync.Cluster{{ID: kv.clusterName, Name: kv.clusterName}}, nil
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID.
+// VirtualMachineInstances that are not owned by a VirtualMachine are
+// included as well.
+func (kv *KubevirtProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	ctx := context.Background()
+	machines, instances, claims, err := kv.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0, len(machines))
+	for _, machine := range machines {
+		key := objectKey(machine.Metadata)
+		instance, running := instances[key]
+		vm := kv.toVM(machine.Metadata, machine.Spec.Template.Spec, claims)
+		vm.PowerState = machine.Status.PrintableStatus
+		if running {
+			addInterfaces(&vm, instance)
+			delete(instances, key)
+		}
+		setStatus(&vm)
+		vms = append(vms, vm)
+	}
+	keys := make([]string, 0, len(instances))
+	for key := range instances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		instance := instances[key]
+		vm := kv.toVM(instance.Metadata, instance.Spec, claims)
+		vm.PowerState = instance.Status.Phase
+		addInterfaces(&vm, instance)
+		setStatus(&vm)
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// load returns the VirtualMachines, the VirtualMachineInstances and the
+// PersistentVolumeClaims by namespace/name of the synced namespaces
+func (kv *KubevirtProvider) load(ctx context.Context) ([]virtualMachine, map[string]virtualMachineInstance, map[string]persistentVolumeClaim, error) {
+	namespaces := kv.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	machines := make([]virtualMachine, 0)
+	instances := make(map[string]virtualMachineInstance)
+	claims := make(map[string]persistentVolumeClaim)
+	for _, ns := range namespaces {
+		prefix := ""
+		if ns != "" {
+			prefix = "/namespaces/" + ns
+		}
+		vms, err := list[virtualMachine](ctx, kv.client, "/apis/kubevirt.io/v1"+prefix+"/virtualmachines")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		machines = append(machines, vms...)
+		vmis, err := list[virtualMachineInstance](ctx, kv.client, "/apis/kubevirt.io/v1"+prefix+"/virtualmachineinstances")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, vmi := range vmis {
+			instances[objectKey(vmi.Metadata)] = vmi
+		}
+		pvcs, err := list[persistentVolumeClaim](ctx, kv.client, "/api/v1"+prefix+"/persistentvolumeclaims")
+		if err != nil {
+			kv.log.Warn("could not retrieve persistent volume claims", "namespace", ns, "error", err)
+			continue
+		}
+		for _, pvc := range pvcs {
+			claims[objectKey(pvc.Metadata)] = pvc
+		}
+	}
+	return machines, instances, claims, nil
+}
+
+// objectKey returns the namespace/name of the object
+func objectKey(meta objectMeta) string {
+	return meta.Namespace + "/" + meta.Name
+}
+
+// toVM converts the spec of a VirtualMachine or VirtualMachineInstance into
+// a VM
+func (kv *KubevirtProvider) toVM(meta objectMeta, spec vmiSpec, claims map[string]persistentVolumeClaim) sync.VM {
+	vm := sync.VM{ID: meta.UID, Name: kv.vmName(meta), Type: sync.VMTypeVirtualMachine}
+	vm.Description = meta.Annotations["description"]
+	vm.Metadata = map[string]string{sync.MetaNamespace: meta.Namespace}
+	domain := spec.Domain
+	if domain.Firmware != nil {
+		vm.Serial = domain.Firmware.Serial
+		if vm.Serial == "" {
+			vm.Serial = domain.Firmware.UUID
+		}
+	}
+	vm.VCPUs = 1
+	if cpu := domain.CPU; cpu != nil && cpu.Cores+cpu.Sockets+cpu.Threads > 0 {
+		vm.VCPUs = float32(max(cpu.Cores, 1) * max(cpu.Sockets, 1) * max(cpu.Threads, 1))
+	} else if value, ok := domain.Resources.Requests["cpu"]; ok {
+		if cpus, err := parseQuantity(value); err == nil && cpus > 0 {
+			vm.VCPUs = float32(cpus)
+		}
+	}
+	memory := domain.Resources.Requests["memory"]
+	if domain.Memory != nil && domain.Memory.Guest != "" {
+		memory = domain.Memory.Guest
+	} else if memory == "" {
+		memory = domain.Resources.Limits["memory"]
+	}
+	if bytes, err := parseQuantity(memory); err == nil {
+		vm.Memory = int(bytes / mb)
+	} else {
+		kv.log.Warn("invalid memory", "vm", meta.Name, "error", err)
+	}
+	cdroms := make(map[string]bool)
+	for _, disk := range domain.Devices.Disks {
+		if len(disk.CDRom) > 0 {
+			cdroms[disk.Name] = true
+		}
+	}
+	var diskspace int64
+	for _, volume := range spec.Volumes {
+		claimName := ""
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		case volume.DataVolume != nil:
+			// DataVolumes are backed by a claim of the same name
+			claimName = volume.DataVolume.Name
+		}
+		if claimName == "" || cdroms[volume.Name] {
+			continue
+		}
+		disk := sync.Disk{ID: volume.Name, Name: volume.Name, Description: claimName}
+		if claim, ok := claims[meta.Namespace+"/"+claimName]; ok {
+			size, err := claim.size()
+			if err != nil {
+				kv.log.Warn("invalid claim size", "vm", meta.Name, "claim", claimName, "error", err)
+			}
+			disk.Size = size
+			diskspace += size
+		}
+		vm.Disks = append(vm.Disks, disk)
+	}
+	vm.Diskspace = int(diskspace / gb)
+	networks := make(map[string]string)
+	for _, network := range spec.Networks {
+		switch {
+		case network.Multus != nil:
+			networks[network.Name] = network.Multus.NetworkName
+		case network.Pod != nil:
+			networks[network.Name] = "pod"
+		}
+	}
+	vm.Network = make([]sync.NIC, 0)
+	for _, intf := range domain.Devices.Interfaces {
+		nic := sync.NIC{ID: intf.Name, Name: intf.Name, MAC: strings.ToUpper(intf.MacAddress)}
+		nic.Type = intf.Model
+		nic.Network = networks[intf.Name]
+		vm.Network = append(vm.Network, nic)
+	}
+	return vm
+}
+
+// vmName returns the name of the VM in the name format
+func (kv *KubevirtProvider) vmName(meta objectMeta) string {
+	switch kv.nameFormat {
+	case NameQualified:
+		return meta.Namespace + "/" + meta.Name
+	case NameDotted:
+		return meta.Name + "." + meta.Namespace
+	}
+	return meta.Name
+}
+
+// addInterfaces adds the MAC and IP addresses reported in the status of the
+// running instance to the interfaces of the VM
+func addInterfaces(vm *sync.VM, instance virtualMachineInstance) {
+	for _, status := range instance.Status.Interfaces {
+		var nic *sync.NIC
+		for i := range vm.Network {
+			if vm.Network[i].Name == status.Name || (status.MAC != "" && strings.EqualFold(vm.Network[i].MAC, status.MAC)) {
+				nic = &vm.Network[i]
+				break
+			}
+		}
+		if nic == nil {
+			continue // interfaces of the guest that KubeVirt did not define
+		}
+		if nic.MAC == "" {
+			nic.MAC = strings.ToUpper(status.MAC)
+		}
+		source := sync.IPSourceConfig
+		if strings.Contains(status.InfoSource, "guest-agent") {
+			source = sync.IPSourceAgent
+		}
+		addresses := status.IPAddresses
+		if len(addresses) == 0 && status.IPAddress != "" {
+			addresses = []string{status.IPAddress}
+		}
+		for _, ip := range addresses {
+			nic.AddIP(hostAddress(ip), source)
+		}
+	}
+}
+
+// hostAddress returns the address as a host route, /32 or /128, unless it
+// already has a prefix length
+func hostAddress(ip string) string {
+	if strings.Contains(ip, "/") {
+		return ip
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+// setStatus sets the status of the VM from its power state
+func setStatus(vm *sync.VM) {
+	if strings.EqualFold(vm.PowerState, "Running") {
+		vm.Status = "active"
+	} else {
+		vm.Status = "offline"
+	}
+}
+
+// ParseNamespaces converts a comma separated list of namespaces
+func ParseNamespaces(value string) []string {
+	namespaces := make([]string, 0)
+	for _, ns := range strings.Split(value, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
@@ -0,0 +1,189 @@
+package kubevirt
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+		err      string
+	}{
+		{value: "", expected: 0},
+		{value: "128974848", expected: 128974848},
+		{value: "4Gi", expected: 4 << 30},
+		{value: "4G", expected: 4e9},
+		{value: " 1Ti ", expected: 1 << 40},
+		{value: "2Ki", expected: 2048},
+		{value: "2k", expected: 2000},
+		{value: "1.5Mi", expected: 1.5 * (1 << 20)},
+		{value: "1M", expected: 1e6},
+		{value: "500m", expected: 0.5},
+		{value: "1e3", expected: 1000},
+		{value: "4Gx", err: `invalid quantity "4Gx"`},
+		{value: "Gi", err: `invalid quantity "Gi"`},
+		{value: "abcMi", err: `invalid quantity "abcMi"`},
+	}
+	for _, test := range tests {
+		q, err := parseQuantity(test.value)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: error %v, expected %s", test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil || q != test.expected {
+			t.Errorf("%q: %v, %v, expected %v", test.value, q, err, test.expected)
+		}
+	}
+}
+
+func TestToVMResources(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		vcpus  float32
+		memory int
+	}{
+		{
+			name:   "topology before requests",
+			domain: `{"cpu": {"cores": 2, "sockets": 2}, "resources": {"requests": {"cpu": "8", "memory": "4Gi"}}}`,
+			vcpus:  4, memory: 4096,
+		},
+		{
+			name:   "threads",
+			domain: `{"cpu": {"threads": 2}, "resources": {"requests": {"memory": "1Gi"}}}`,
+			vcpus:  2, memory: 1024,
+		},
+		{
+			name:   "cpu requests",
+			domain: `{"resources": {"requests": {"cpu": "1500m", "memory": "2Gi"}}}`,
+			vcpus:  1.5, memory: 2048,
+		},
+		{
+			name:   "empty topology",
+			domain: `{"cpu": {}, "resources": {"requests": {"cpu": "3"}}}`,
+			vcpus:  3,
+		},
+		{
+			name:   "default cpu",
+			domain: `{"resources": {"limits": {"cpu": "4", "memory": "8Gi"}}}`,
+			vcpus:  1, memory: 8192,
+		},
+		{
+			name:   "guest memory before requests",
+			domain: `{"memory": {"guest": "2Gi"}, "resources": {"requests": {"memory": "3Gi"}, "limits": {"memory": "4Gi"}}}`,
+			vcpus:  1, memory: 2048,
+		},
+		{
+			name:   "requests before limits",
+			domain: `{"memory": {}, "resources": {"requests": {"memory": "3Gi"}, "limits": {"memory": "4Gi"}}}`,
+			vcpus:  1, memory: 3072,
+		},
+		{
+			name:   "decimal limits",
+			domain: `{"resources": {"limits": {"memory": "1G"}}}`,
+			vcpus:  1, memory: 953,
+		},
+		{
+			name:   "invalid memory",
+			domain: `{"resources": {"requests": {"cpu": "x", "memory": "lots"}}}`,
+			vcpus:  1,
+		},
+	}
+	kv := &KubevirtProvider{log: slog.New(slog.NewTextHandler(io.Discard, nil)), nameFormat: NameQualified}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := vmiSpec{}
+			if err := json.Unmarshal([]byte(`{"domain": `+test.domain+`}`), &spec); err != nil {
+				t.Fatal(err)
+			}
+			vm := kv.toVM(objectMeta{Name: "web1", Namespace: "apps", UID: "uid-1"}, spec, nil)
+			if vm.VCPUs != test.vcpus || vm.Memory != test.memory {
+				t.Errorf("%v vCPUs and %d MB, expected %v and %d", vm.VCPUs, vm.Memory, test.vcpus, test.memory)
+			}
+		})
+	}
+}
+
+func TestVMName(t *testing.T) {
+	tests := []struct {
+		format   string
+		rules    string
+		expected string
+	}{
+		{format: NameAuto, expected: "apps/web1"},
+		{format: NameAuto, rules: "namespace:tag", expected: "apps/web1"},
+		{format: NameAuto, rules: "owner:role,namespace:tenant", expected: "web1"},
+		{format: NameQualified, rules: "namespace:tenant", expected: "apps/web1"},
+		{format: NameDotted, expected: "web1.apps"},
+		{format: NameBare, expected: "web1"},
+	}
+	meta := objectMeta{Name: "web1", Namespace: "apps", UID: "uid-1"}
+	for _, test := range tests {
+		rules, err := sync.ParseMetadataRules(test.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kv := &KubevirtProvider{log: slog.New(slog.NewTextHandler(io.Discard, nil)), nameFormat: ResolveNameFormat(test.format, rules)}
+		vm := kv.toVM(meta, vmiSpec{}, nil)
+		if vm.Name != test.expected || vm.ID != "uid-1" || vm.Metadata[sync.MetaNamespace] != "apps" {
+			t.Errorf("%s with rules %q: VM %s (%s), expected %s", test.format, test.rules, vm.Name, vm.ID, test.expected)
+		}
+	}
+}
+
+func TestNewKubevirtProviderNameFormat(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := NewKubevirtProvider("https://127.0.0.1:1", "", "", logger, WithNameFormat(NameAuto))
+	if expected := `invalid name format "auto", expected namespace/name, name.namespace or name`; err == nil || err.Error() != expected {
+		t.Errorf("error %v, expected %s", err, expected)
+	}
+}
+
+func TestNewKubevirtProviderTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"gitVersion": "v1.30.0"}`)
+	}))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if _, err := NewKubevirtProvider(srv.URL, "", "token", logger); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("error %v, expected the certificate to be verified", err)
+	}
+	kv, err := NewKubevirtProvider(srv.URL, "", "token", logger, WithCAFile(caFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kv.clusterName != "127.0.0.1" {
+		t.Errorf("cluster %s, expected the API server host", kv.clusterName)
+	}
+	if _, err = NewKubevirtProvider(srv.URL, "", "token", logger, WithInsecure(true)); err != nil {
+		t.Error(err)
+	}
+	if _, err = NewKubevirtProvider(srv.URL, "", "token", logger, WithCAFile(filepath.Join(t.TempDir(), "missing.crt"))); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
@@ -0,0 +1,154 @@
+package kubevirt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type objectMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	UID         string            `json:"uid"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// virtualMachine is a KubeVirt VirtualMachine
+type virtualMachine struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Template struct {
+			Spec vmiSpec `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+	Status struct {
+		PrintableStatus string `json:"printableStatus"`
+	} `json:"status"`
+}
+
+// virtualMachineInstance is a KubeVirt VirtualMachineInstance, the running
+// instance of a VirtualMachine
+type virtualMachineInstance struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     vmiSpec    `json:"spec"`
+	Status   struct {
+		Phase      string         `json:"phase"`
+		NodeName   string         `json:"nodeName"`
+		Interfaces []vmiInterface `json:"interfaces"`
+	} `json:"status"`
+}
+
+type vmiSpec struct {
+	Domain struct {
+		CPU *struct {
+			Cores   int `json:"cores"`
+			Sockets int `json:"sockets"`
+			Threads int `json:"threads"`
+		} `json:"cpu"`
+		Memory *struct {
+			Guest string `json:"guest"`
+		} `json:"memory"`
+		Resources struct {
+			Requests map[string]string `json:"requests"`
+			Limits   map[string]string `json:"limits"`
+		} `json:"resources"`
+		Firmware *struct {
+			UUID   string `json:"uuid"`
+			Serial string `json:"serial"`
+		} `json:"firmware"`
+		Devices struct {
+			Disks []struct {
+				Name  string          `json:"name"`
+				CDRom json.RawMessage `json:"cdrom"`
+			} `json:"disks"`
+			Interfaces []struct {
+				Name       string `json:"name"`
+				MacAddress string `json:"macAddress"`
+				Model      string `json:"model"`
+			} `json:"interfaces"`
+		} `json:"devices"`
+	} `json:"domain"`
+	Networks []struct {
+		Name   string    `json:"name"`
+		Pod    *struct{} `json:"pod"`
+		Multus *struct {
+			NetworkName string `json:"networkName"`
+		} `json:"multus"`
+	} `json:"networks"`
+	Volumes []struct {
+		Name                  string `json:"name"`
+		PersistentVolumeClaim *struct {
+			ClaimName string `json:"claimName"`
+		} `json:"persistentVolumeClaim"`
+		DataVolume *struct {
+			Name string `json:"name"`
+		} `json:"dataVolume"`
+	} `json:"volumes"`
+}
+
+// vmiInterface is an interface in the status of a VirtualMachineInstance
+type vmiInterface struct {
+	Name          string   `json:"name"`
+	MAC           string   `json:"mac"`
+	IPAddress     string   `json:"ipAddress"`
+	IPAddresses   []string `json:"ipAddresses"`
+	InterfaceName string   `json:"interfaceName"`
+	InfoSource    string   `json:"infoSource"`
+}
+
+// persistentVolumeClaim is a Kubernetes PersistentVolumeClaim
+type persistentVolumeClaim struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Resources struct {
+			Requests map[string]string `json:"requests"`
+		} `json:"resources"`
+	} `json:"spec"`
+	Status struct {
+		Capacity map[string]string `json:"capacity"`
+	} `json:"status"`
+}
+
+// size returns the capacity of the claim in bytes, or the requested size
+// if the claim is not bound yet
+func (p persistentVolumeClaim) size() (int64, error) {
+	value := p.Status.Capacity["storage"]
+	if value == "" {
+		value = p.Spec.Resources.Requests["storage"]
+	}
+	q, err := parseQuantity(value)
+	return int64(q), err
+}
+
+// quantitySuffixes are the multipliers of the Kubernetes quantity suffixes
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+	{"m", 1e-3},
+}
+
+// parseQuantity parses a Kubernetes resource quantity, eg. 4Gi or 500m
+func parseQuantity(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	number, multiplier := value, 1.0
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(value, s.suffix) {
+			number = strings.TrimSuffix(value, s.suffix)
+			multiplier = s.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", value)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,37 @@
+package kubevirt
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "kubevirt",
+		Description: "KubeVirt on Kubernetes, PROVIDER_URL is a kubeconfig path or the API server URL",
+		Settings: []providers.Setting{
+			{Name: "KUBEVIRT_CLUSTER_NAME", Description: "the Netbox cluster name, defaults to the kubeconfig cluster name"},
+			{Name: "KUBEVIRT_NAMESPACES", Description: "the comma separated namespaces to sync, all by default"},
+			{Name: "KUBEVIRT_VM_NAME", Default: NameAuto, Description: "the VM name format, auto, namespace/name, name.namespace or name"},
+			{Name: "KUBEVIRT_CA_FILE", Description: "the CA certificates verifying the API server URL, the system ones by default"},
+			{Name: "KUBEVIRT_INSECURE", Default: "false", Description: "skip the certificate verification of the API server URL"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			insecure, err := cfg.Bool("KUBEVIRT_INSECURE")
+			if err != nil {
+				return nil, err
+			}
+			rules, err := sync.ParseMetadataRules(cfg.Get("METADATA_RULES"))
+			if err != nil {
+				return nil, err
+			}
+			return NewKubevirtProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithClusterName(cfg.Get("KUBEVIRT_CLUSTER_NAME")),
+				WithNamespaces(ParseNamespaces(cfg.Get("KUBEVIRT_NAMESPACES"))),
+				WithNameFormat(ResolveNameFormat(cfg.Get("KUBEVIRT_VM_NAME"), rules)),
+				WithCAFile(cfg.Get("KUBEVIRT_CA_FILE")),
+				WithInsecure(insecure),
+			)
+		},
+	})
+}
@@ -0,0 +1,201 @@
+package libvirt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// domain is the part of the libvirt domain XML used by the sync
+type domain struct {
+	XMLName     xml.Name `xml:"domain"`
+	Type        string   `xml:"type,attr"`
+	ID          string   `xml:"id,attr"`
+	Name        string   `xml:"name"`
+	UUID        string   `xml:"uuid"`
+	Title       string   `xml:"title"`
+	Description string   `xml:"description"`
+	Memory      size     `xml:"memory"`
+	CurrentMem  size     `xml:"currentMemory"`
+	VCPU        struct {
+		Current string `xml:"current,attr"`
+		Value   string `xml:",chardata"`
+	} `xml:"vcpu"`
+	Sysinfo struct {
+		System []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"system>entry"`
+	} `xml:"sysinfo"`
+	Devices struct {
+		Disks      []domainDisk      `xml:"disk"`
+		Interfaces []domainInterface `xml:"interface"`
+	} `xml:"devices"`
+}
+
+// size is a libvirt size element with a unit attribute
+type size struct {
+	Unit  string `xml:"unit,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Bytes returns the size in bytes.  Sizes without a unit are KiB.
+func (s size) Bytes() (int64, error) {
+	value := strings.TrimSpace(s.Value)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	multiplier := int64(1)
+	switch strings.ToLower(s.Unit) {
+	case "b", "bytes":
+	case "", "k", "kib":
+		multiplier = 1 << 10
+	case "kb":
+		multiplier = 1000
+	case "m", "mib":
+		multiplier = 1 << 20
+	case "mb":
+		multiplier = 1000 * 1000
+	case "g", "gib":
+		multiplier = 1 << 30
+	case "gb":
+		multiplier = 1000 * 1000 * 1000
+	case "t", "tib":
+		multiplier = 1 << 40
+	case "tb":
+		multiplier = 1000 * 1000 * 1000 * 1000
+	default:
+		return 0, fmt.Errorf("invalid size unit %q", s.Unit)
+	}
+	return n * multiplier, nil
+}
+
+type domainDisk struct {
+	Type   string `xml:"type,attr"`
+	Device string `xml:"device,attr"`
+	Source struct {
+		File   string `xml:"file,attr"`
+		Dev    string `xml:"dev,attr"`
+		Pool   string `xml:"pool,attr"`
+		Volume string `xml:"volume,attr"`
+		Name   string `xml:"name,attr"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+}
+
+// source returns the file, device, volume or network name of the disk
+func (d domainDisk) source() string {
+	switch {
+	case d.Source.File != "":
+		return d.Source.File
+	case d.Source.Dev != "":
+		return d.Source.Dev
+	case d.Source.Volume != "":
+		return fmt.Sprintf("%s/%s", d.Source.Pool, d.Source.Volume)
+	}
+	return d.Source.Name
+}
+
+type domainInterface struct {
+	Type string `xml:"type,attr"`
+	MAC  struct {
+		Address string `xml:"address,attr"`
+	} `xml:"mac"`
+	Source struct {
+		Network string `xml:"network,attr"`
+		Bridge  string `xml:"bridge,attr"`
+		Dev     string `xml:"dev,attr"`
+	} `xml:"source"`
+	Model struct {
+		Type string `xml:"type,attr"`
+	} `xml:"model"`
+	Link struct {
+		State string `xml:"state,attr"`
+	} `xml:"link"`
+}
+
+// network returns the network, bridge or device the interface is attached to
+func (i domainInterface) network() string {
+	switch {
+	case i.Source.Network != "":
+		return i.Source.Network
+	case i.Source.Bridge != "":
+		return i.Source.Bridge
+	}
+	return i.Source.Dev
+}
+
+// parseDomain parses the domain XML
+func parseDomain(data []byte) (domain, error) {
+	dom := domain{}
+	if err := xml.Unmarshal(data, &dom); err != nil {
+		return dom, err
+	}
+	if dom.Name == "" {
+		return dom, fmt.Errorf("domain has no name")
+	}
+	return dom, nil
+}
+
+// toVM converts the domain into a VM.  Disk sizes, addresses and the
+// power state are added by the provider as they are not in the XML.
+func (d domain) toVM() (sync.VM, error) {
+	vm := sync.VM{ID: d.UUID, Name: d.Name, Type: sync.VMTypeVirtualMachine}
+	if vm.ID == "" {
+		vm.ID = d.Name
+	}
+	vm.Description = d.Description
+	vm.Serial = d.UUID
+	for _, entry := range d.Sysinfo.System {
+		if entry.Name == "serial" && strings.TrimSpace(entry.Value) != "" {
+			vm.Serial = strings.TrimSpace(entry.Value)
+		}
+	}
+	memory := d.CurrentMem
+	if strings.TrimSpace(memory.Value) == "" {
+		memory = d.Memory
+	}
+	bytes, err := memory.Bytes()
+	if err != nil {
+		return vm, fmt.Errorf("memory: %w", err)
+	}
+	vm.Memory = int(bytes / mb)
+	vcpus := d.VCPU.Current
+	if vcpus == "" {
+		vcpus = d.VCPU.Value
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(vcpus)); err == nil {
+		vm.VCPUs = float32(n)
+	}
+	for _, disk := range d.Devices.Disks {
+		if disk.Device != "" && disk.Device != "disk" && disk.Device != "lun" {
+			continue // skip cdroms and floppies
+		}
+		vm.Disks = append(vm.Disks, sync.Disk{ID: disk.Target.Dev, Name: disk.Target.Dev, Description: disk.source()})
+	}
+	vm.Network = make([]sync.NIC, 0)
+	for idx, intf := range d.Devices.Interfaces {
+		// The target device (eg. vnet0) changes when the domain is started,
+		// so interfaces are named by their position
+		nic := sync.NIC{ID: fmt.Sprintf("net%d", idx), MAC: strings.ToUpper(intf.MAC.Address)}
+		nic.Name = nic.ID
+		nic.Type = intf.Model.Type
+		nic.Network = intf.network()
+		if intf.Link.State != "" {
+			enabled := intf.Link.State == "up"
+			nic.Enabled = &enabled
+		}
+		vm.Network = append(vm.Network, nic)
+	}
+	return vm, nil
+}
@@ -0,0 +1,265 @@
+// Package libvirt syncs the domains of KVM hosts managed by libvirt.  Hosts
+// are reached with virsh through any libvirt URI (qemu:///system,
+// qemu+ssh://user@host/system, ...) or read offline from dumped domain XML.
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*LibvirtProvider)(nil)
+
+const mb = 1048576
+const gb = 1073741824
+
+// offlineScheme is the URL scheme of a directory of dumped domain XML files
+const offlineScheme = "file"
+
+// host is a libvirt host, which is synced as a cluster
+type host struct {
+	Name string
+	// virsh is nil for offline hosts
+	virsh *virsh
+	// dir holds the domain XML files of offline hosts
+	dir string
+}
+
+type LibvirtProvider struct {
+	log   pkg.Logger
+	hosts []host
+}
+
+// NewLibvirtProvider creates a new VM sync provider for libvirt hosts.
+// baseURL is a comma separated list of libvirt URIs.  A file:// URL is read
+// offline: each subdirectory is a host holding the domain XML files dumped
+// with virsh dumpxml, or the directory itself is a host if it holds XML files.
+func NewLibvirtProvider(baseURL string, username string, password string, logger pkg.Logger) (*LibvirtProvider, error) {
+	lv := &LibvirtProvider{log: logger}
+	if log, ok := logger.(*slog.Logger); ok {
+		lv.log = log.With("provider", lv.GetName())
+	}
+	ctx := context.Background()
+	for _, uri := range strings.Split(baseURL, ",") {
+		uri = strings.TrimSpace(uri)
+		if uri == "" {
+			continue
+		}
+		u, err := url.Parse(uri)
+		if err != nil {
+			return lv, fmt.Errorf("invalid libvirt URI %q: %w", uri, err)
+		}
+		if u.Scheme == offlineScheme {
+			hosts, err := offlineHosts(u.Path)
+			if err != nil {
+				return lv, err
+			}
+			lv.hosts = append(lv.hosts, hosts...)
+			continue
+		}
+		h := host{Name: u.Hostname(), virsh: newVirsh(uri)}
+		lv.log.Info("Connecting...", "uri", uri)
+		if name, err := h.virsh.hostname(ctx); err == nil && name != "" {
+			h.Name = name
+		} else if err != nil {
+			return lv, err
+		}
+		if h.Name == "" {
+			h.Name = "localhost"
+		}
+		lv.hosts = append(lv.hosts, h)
+	}
+	if len(lv.hosts) == 0 {
+		return lv, errors.New("no libvirt URI given")
+	}
+	// Hosts are clusters by name, so a duplicate would hide the VMs of the
+	// other host
+	seen := make(map[string]string)
+	for _, h := range lv.hosts {
+		origin := h.dir
+		if h.virsh != nil {
+			origin = h.virsh.uri
+		}
+		if first, ok := seen[h.Name]; ok {
+			return lv, fmt.Errorf("duplicate libvirt host %q from %s and %s", h.Name, first, origin)
+		}
+		seen[h.Name] = origin
+	}
+	return lv, nil
+}
+
+// offlineHosts returns the hosts of the offline directory
+func offlineHosts(dir string) ([]host, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]host, 0)
+	hasXML := false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			hosts = append(hosts, host{Name: entry.Name(), dir: filepath.Join(dir, entry.Name())})
+		} else if strings.EqualFold(filepath.Ext(entry.Name()), ".xml") {
+			hasXML = true
+		}
+	}
+	if hasXML {
+		hosts = append(hosts, host{Name: filepath.Base(dir), dir: dir})
+	}
+	return hosts, nil
+}
+
+func (lv *LibvirtProvider) GetName() string {
+	return "libvirt"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider
+func (lv *LibvirtProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	dc := sync.Datacenter{ID: lv.GetName(), Name: lv.GetName(), Description: "libvirt hosts"}
+	return []sync.Datacenter{dc}, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID.  Every
+// libvirt host is a cluster.
+func (lv *LibvirtProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	clusters := make([]sync.Cluster, 0, len(lv.hosts))
+	for _, h := range lv.hosts {
+		clusters = append(clusters, sync.Cluster{ID: h.Name, Name: h.Name})
+	}
+	return clusters, nil
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (lv *LibvirtProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	for _, h := range lv.hosts {
+		if h.Name != clusterID {
+			continue
+		}
+		if h.virsh == nil {
+			return lv.offlineVMs(h)
+		}
+		return lv.hostVMs(context.Background(), h)
+	}
+	return nil, fmt.Errorf("unknown libvirt host %s", clusterID)
+}
+
+// hostVMs returns the domains of a host reached with virsh
+func (lv *LibvirtProvider) hostVMs(ctx context.Context, h host) ([]sync.VM, error) {
+	domains, err := h.virsh.listDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0, len(domains))
+	for _, ds := range domains {
+		if ds.Err != nil {
+			lv.log.Error("could not retrieve domain state", "vm", ds.Name, "error", ds.Err)
+			vms = append(vms, sync.VM{ID: ds.Name, Name: ds.Name, Err: ds.Err})
+			continue
+		}
+		data, err := h.virsh.dumpXML(ctx, ds.Name)
+		if err != nil {
+			lv.log.Error("could not retrieve domain XML", "vm", ds.Name, "error", err)
+			vms = append(vms, sync.VM{ID: ds.Name, Name: ds.Name, Err: err})
+			continue
+		}
+		dom, err := parseDomain(data)
+		if err != nil {
+			lv.log.Error("could not parse domain XML", "vm", ds.Name, "error", err)
+			vms = append(vms, sync.VM{ID: ds.Name, Name: ds.Name, Err: err})
+			continue
+		}
+		vm, err := dom.toVM()
+		if err != nil {
+			lv.log.Warn("could not convert domain", "vm", ds.Name, "error", err)
+		}
+		setStatus(&vm, ds.State)
+		var diskspace int64
+		for i, disk := range vm.Disks {
+			capacity, err := h.virsh.blockCapacity(ctx, ds.Name, disk.ID)
+			if err != nil {
+				lv.log.Debug("could not retrieve disk capacity", "vm", vm.Name, "disk", disk.ID, "error", err)
+				continue
+			}
+			vm.Disks[i].Size = capacity
+			diskspace += capacity
+		}
+		vm.Diskspace = int(diskspace / gb)
+		if ds.State == "running" {
+			lv.addAddresses(ctx, h, &vm)
+		}
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// addAddresses adds the addresses reported by the guest agent and the
+// DHCP leases of libvirt networks to the interfaces of the VM
+func (lv *LibvirtProvider) addAddresses(ctx context.Context, h host, vm *sync.VM) {
+	for _, source := range []struct {
+		name     string
+		ipSource string
+	}{{"agent", sync.IPSourceAgent}, {"lease", sync.IPSourceConfig}} {
+		addresses, err := h.virsh.interfaceAddresses(ctx, vm.Name, source.name)
+		if err != nil {
+			lv.log.Debug("could not retrieve interface addresses", "vm", vm.Name, "source", source.name, "error", err)
+			continue
+		}
+		for i := range vm.Network {
+			for _, ip := range addresses[vm.Network[i].MAC] {
+				vm.Network[i].AddIP(ip, source.ipSource)
+			}
+		}
+	}
+}
+
+// offlineVMs returns the domains of the XML files of an offline host
+func (lv *LibvirtProvider) offlineVMs(h host) ([]sync.VM, error) {
+	files, err := filepath.Glob(filepath.Join(h.dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	vms := make([]sync.VM, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		dom, err := parseDomain(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		vm, err := dom.toVM()
+		if err != nil {
+			lv.log.Warn("could not convert domain", "file", file, "error", err)
+		}
+		// Running domains have an id in the dumped XML
+		state := "shut off"
+		if dom.ID != "" && dom.ID != "-1" {
+			state = "running"
+		}
+		setStatus(&vm, state)
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// setStatus sets the power state and status of the VM from the domain state
+func setStatus(vm *sync.VM, state string) {
+	vm.PowerState = state
+	if state == "running" {
+		vm.Status = "active"
+	} else {
+		vm.Status = "offline"
+	}
+}
@@ -0,0 +1,186 @@
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+const webName = "web server 1"
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeVirsh answers virsh commands from the outputs by their arguments,
+// failing on any other command
+func fakeVirsh(t *testing.T, uri string, outputs map[string]string) *virsh {
+	t.Helper()
+	return &virsh{uri: uri, run: func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) < 3 || args[0] != "--connect" || args[1] != uri || args[2] != "--quiet" {
+			t.Errorf("virsh called with %q", args)
+			return nil, errors.New("invalid arguments")
+		}
+		key := strings.Join(args[3:], "|")
+		out, ok := outputs[key]
+		if !ok {
+			return nil, fmt.Errorf("virsh %s: exit status 1: error: failed to get domain", strings.Join(args[3:], " "))
+		}
+		return []byte(out), nil
+	}}
+}
+
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// checkWeb checks the VM of testdata/web.xml
+func checkWeb(t *testing.T, vm sync.VM) {
+	t.Helper()
+	if vm.ID != "3e3fce45-4f53-4fa7-bb32-11f34168b82b" || vm.Name != webName || vm.Description != "Web server" ||
+		vm.Serial != "CZ1234" || vm.Memory != 4096 || vm.VCPUs != 2 || vm.Status != "active" || vm.PowerState != "running" {
+		t.Errorf("web is %+v", vm)
+	}
+	if len(vm.Disks) != 2 || vm.Disks[0].ID != "vda" || vm.Disks[0].Description != "/var/lib/libvirt/images/web.qcow2" ||
+		vm.Disks[1].ID != "vdb" || vm.Disks[1].Description != "data/web-data" {
+		t.Errorf("web disks %+v, expected vda and vdb without the cdrom", vm.Disks)
+	}
+	if len(vm.Network) != 2 {
+		t.Fatalf("web NICs %+v", vm.Network)
+	}
+	net0, net1 := vm.Network[0], vm.Network[1]
+	if net0.Name != "net0" || net0.MAC != "52:54:00:12:34:56" || net0.Network != "default" || net0.Type != "virtio" || net0.Enabled != nil {
+		t.Errorf("net0 is %+v", net0)
+	}
+	if net1.Name != "net1" || net1.MAC != "52:54:00:AB:CD:EF" || net1.Network != "br0" || net1.Enabled == nil || *net1.Enabled {
+		t.Errorf("net1 is %+v", net1)
+	}
+}
+
+func TestHostVMs(t *testing.T) {
+	uri := "qemu+ssh://root@kvm1/system"
+	outputs := map[string]string{
+		"list|--all|--name":              webName + "\ndb1\ngone\n\n",
+		"domstate|" + webName:            "running\n\n",
+		"domstate|db1":                   "shut off\n\n",
+		"dumpxml|" + webName:             readTestdata(t, "web.xml"),
+		"dumpxml|db1":                    "<domain type='kvm'><name>db1</name><uuid>db1-uuid</uuid><memory unit='GiB'>2</memory><vcpu>1</vcpu></domain>",
+		"domblkinfo|" + webName + "|vda": "Capacity:       21474836480\nAllocation:     3221225472\nPhysical:       3221225472\n",
+		"domblkinfo|" + webName + "|vdb": "Capacity:       10737418240\n",
+		"domifaddr|" + webName + "|--source|agent": ` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ lo         00:00:00:00:00:00    ipv4         127.0.0.1/8
+ eth0       52:54:00:12:34:56    ipv4         192.168.122.10/24
+ -          -                    ipv6         fe80::5054:ff:fe12:3456/64
+`,
+		"domifaddr|" + webName + "|--source|lease": ` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ vnet0      52:54:00:12:34:56    ipv4         192.168.122.10/32
+ vnet0      52:54:00:12:34:56    ipv4         192.168.122.11/24
+ vnet1      52:54:00:ab:cd:ef    ipv4         10.0.0.20/24
+`,
+	}
+	lv := &LibvirtProvider{log: testLogger(), hosts: []host{{Name: "kvm1", virsh: fakeVirsh(t, uri, outputs)}}}
+	vms, err := lv.GetClusterVMs("kvm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 3 {
+		t.Fatalf("%d VMs, expected 3", len(vms))
+	}
+	web, db, gone := vms[0], vms[1], vms[2]
+	checkWeb(t, web)
+	if web.Disks[0].Size != 20*gb || web.Disks[1].Size != 10*gb || web.Diskspace != 30 {
+		t.Errorf("web disks %+v, space %d", web.Disks, web.Diskspace)
+	}
+	net0, net1 := web.Network[0], web.Network[1]
+	if expected := []string{"192.168.122.10/24", "fe80::5054:ff:fe12:3456/64", "192.168.122.11/24"}; !reflect.DeepEqual(net0.IP, expected) {
+		t.Errorf("net0 IPs %v, expected %v", net0.IP, expected)
+	}
+	if expected := []string{"192.168.122.10/24", "fe80::5054:ff:fe12:3456/64"}; !reflect.DeepEqual(net0.PreferredIPs(), expected) {
+		t.Errorf("net0 preferred IPs %v, expected the agent addresses %v", net0.PreferredIPs(), expected)
+	}
+	if expected := []string{"10.0.0.20/24"}; !reflect.DeepEqual(net1.PreferredIPs(), expected) || net1.IPSources["10.0.0.20/24"] != sync.IPSourceConfig {
+		t.Errorf("net1 IPs %v from %v, expected the lease %v", net1.IP, net1.IPSources, expected)
+	}
+	if db.ID != "db1-uuid" || db.Status != "offline" || db.PowerState != "shut off" || db.Memory != 2048 || db.Err != nil {
+		t.Errorf("db1 is %+v", db)
+	}
+	if gone.Name != "gone" || gone.Err == nil {
+		t.Errorf("gone is %+v, expected the error of its state", gone)
+	}
+}
+
+// writeHost writes the domain XML files into the host directory of dir
+func writeHost(t *testing.T, dir string, name string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOfflineHosts(t *testing.T) {
+	dir := t.TempDir()
+	writeHost(t, dir, "kvm1", map[string]string{"web.xml": readTestdata(t, "web.xml"), "notes.txt": "not a domain"})
+	writeHost(t, dir, "kvm2", map[string]string{"db1.xml": "<domain type='kvm' id='-1'><name>db1</name><memory>1048576</memory></domain>"})
+	lv, err := NewLibvirtProvider("file://"+dir, "", "", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := lv.GetDcClusters(lv.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || clusters[0].Name != "kvm1" || clusters[1].Name != "kvm2" {
+		t.Fatalf("clusters %+v, expected kvm1 and kvm2", clusters)
+	}
+	vms, err := lv.GetClusterVMs("kvm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 1 {
+		t.Fatalf("%d VMs, expected 1", len(vms))
+	}
+	checkWeb(t, vms[0])
+	if vms[0].Disks[0].Size != 0 || len(vms[0].Network[0].IP) != 0 {
+		t.Errorf("web has sizes or addresses from an offline file: %+v", vms[0])
+	}
+	vms, err = lv.GetClusterVMs("kvm2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 1 || vms[0].ID != "db1" || vms[0].Status != "offline" || vms[0].Memory != 1024 {
+		t.Errorf("kvm2 VMs %+v", vms)
+	}
+}
+
+func TestDuplicateHosts(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeHost(t, dir1, "kvm1", nil)
+	writeHost(t, dir1, "kvm2", nil)
+	writeHost(t, dir2, "kvm2", nil)
+	_, err := NewLibvirtProvider("file://"+dir1+", file://"+dir2, "", "", testLogger())
+	expected := fmt.Sprintf("duplicate libvirt host %q from %s and %s", "kvm2", filepath.Join(dir1, "kvm2"), filepath.Join(dir2, "kvm2"))
+	if err == nil || err.Error() != expected {
+		t.Errorf("error %v, expected %s", err, expected)
+	}
+}
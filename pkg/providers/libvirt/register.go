@@ -0,0 +1,16 @@
+package libvirt
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "libvirt",
+		Description: "libvirt hosts, PROVIDER_URL lists libvirt URIs or a file:// directory of domain XML",
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewLibvirtProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger)
+		},
+	})
+}
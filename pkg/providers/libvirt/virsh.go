@@ -0,0 +1,138 @@
+package libvirt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandTimeout is the time allowed for a single virsh command
+const commandTimeout = 30 * time.Second
+
+// virsh runs virsh commands against the libvirt URI of one host
+type virsh struct {
+	uri string
+	// run executes virsh with the arguments and returns its output
+	run func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+func newVirsh(uri string) *virsh {
+	return &virsh{uri: uri, run: runVirsh}
+}
+
+// runVirsh executes the virsh binary, returning stderr in the error
+func runVirsh(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "virsh", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("virsh %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func (v *virsh) command(ctx context.Context, args ...string) ([]byte, error) {
+	return v.run(ctx, append([]string{"--connect", v.uri, "--quiet"}, args...)...)
+}
+
+// hostname returns the hostname of the libvirt host
+func (v *virsh) hostname(ctx context.Context) (string, error) {
+	out, err := v.command(ctx, "hostname")
+	return strings.TrimSpace(string(out)), err
+}
+
+// domainState is a domain with its state from virsh domstate
+type domainState struct {
+	Name  string
+	State string
+	// Err is set if the state could not be retrieved
+	Err error
+}
+
+// listDomains returns all defined domains and their state.  Domain names
+// may contain spaces, so they are listed one per line and their states
+// retrieved one by one rather than parsed from the virsh list table.
+func (v *virsh) listDomains(ctx context.Context) ([]domainState, error) {
+	out, err := v.command(ctx, "list", "--all", "--name")
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]domainState, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		name := strings.TrimRight(line, "\r")
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		ds := domainState{Name: name}
+		state, err := v.command(ctx, "domstate", name)
+		if err != nil {
+			ds.Err = err
+		}
+		ds.State = strings.TrimSpace(string(state))
+		domains = append(domains, ds)
+	}
+	return domains, nil
+}
+
+// dumpXML returns the XML of the domain
+func (v *virsh) dumpXML(ctx context.Context, name string) ([]byte, error) {
+	return v.command(ctx, "dumpxml", name)
+}
+
+// blockCapacity returns the capacity in bytes of the disk of the domain
+func (v *virsh) blockCapacity(ctx context.Context, name string, target string) (int64, error) {
+	out, err := v.command(ctx, "domblkinfo", name, target)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(key) == "Capacity" {
+			return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no capacity reported for %s", target)
+}
+
+// interfaceAddresses returns the addresses of the domain by MAC address as
+// reported by the source, which is agent, lease or arp
+func (v *virsh) interfaceAddresses(ctx context.Context, name string, source string) (map[string][]string, error) {
+	out, err := v.command(ctx, "domifaddr", name, "--source", source)
+	if err != nil {
+		return nil, err
+	}
+	return parseDomIfAddr(string(out)), nil
+}
+
+// parseDomIfAddr parses the domifaddr table.  Interfaces with several
+// addresses list the name and MAC only on the first line.
+//
+//	Name       MAC address          Protocol     Address
+//	-------------------------------------------------------------------------------
+//	vnet0      52:54:00:12:34:56    ipv4         192.168.122.10/24
+//	-          -                    ipv6         fe80::5054:ff:fe12:3456/64
+func parseDomIfAddr(out string) map[string][]string {
+	addresses := make(map[string][]string)
+	mac := ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || strings.HasPrefix(fields[0], "---") || fields[0] == "Name" {
+			continue
+		}
+		if fields[1] != "-" {
+			mac = strings.ToUpper(fields[1])
+		}
+		if mac == "" || fields[0] == "lo" {
+			continue
+		}
+		addresses[mac] = append(addresses[mac], fields[3])
+	}
+	return addresses
+}
@@ -181,6 +181,7 @@ func (nb *Netbox) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 		vm := &sync.VM{}
 		vm.ID = fmt.Sprintf("%d", nbvm.ID)
 		vm.Description = nbvm.Description
+		vm.Serial = nbvm.Serial
 		vm.Diskspace = nbvm.Diskspace
 		vm.Memory = nbvm.Memory
 		vm.Status = nbvm.Status.Value
@@ -0,0 +1,25 @@
+package netbox
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+	nb "github.com/rsapc/netbox"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "netbox",
+		Description: "another Netbox, PROVIDER_URL and PROVIDER_TOKEN are its URL and API token",
+		Settings: []providers.Setting{
+			{Name: "PROVIDER_FILTER", Description: "the query filter of the cluster groups and clusters synced"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			var filter *string
+			if value := cfg.Get("PROVIDER_FILTER"); value != "" {
+				filter = &value
+			}
+			client := nb.NewClient(cfg.URL, cfg.Token, cfg.Logger)
+			return NewNetboxProvider(client, filter, cfg.Logger)
+		},
+	})
+}
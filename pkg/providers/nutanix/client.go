@@ -0,0 +1,97 @@
+package nutanix
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiPath is the path of the Prism Central v3 API
+const apiPath = "/api/nutanix/v3"
+
+// pageLength is the number of entities requested per list call, the
+// maximum allowed by Prism Central
+const pageLength = 500
+
+// client is a minimal Prism Central v3 API client
+type client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+}
+
+func newClient(baseURL string, username string, password string) *client {
+	return &client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		http: &http.Client{
+			Timeout: 120 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		},
+	}
+}
+
+// post sends the body to the API path and decodes the response into result
+func (c *client) post(ctx context.Context, path string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+apiPath+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("POST %s: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// listResponse is a page of a v3 list call
+type listResponse[T any] struct {
+	Metadata struct {
+		TotalMatches int `json:"total_matches"`
+		Length       int `json:"length"`
+		Offset       int `json:"offset"`
+	} `json:"metadata"`
+	Entities []T `json:"entities"`
+}
+
+// list returns all entities of the kind, eg. vm for the vms/list call,
+// requesting them a page at a time
+func list[T any](ctx context.Context, c *client, kind string) ([]T, error) {
+	entities := make([]T, 0)
+	for offset := 0; ; {
+		page := listResponse[T]{}
+		body := map[string]any{"kind": kind, "length": pageLength, "offset": offset}
+		if err := c.post(ctx, "/"+kind+"s/list", body, &page); err != nil {
+			return nil, err
+		}
+		entities = append(entities, page.Entities...)
+		offset += len(page.Entities)
+		if len(page.Entities) == 0 || offset >= page.Metadata.TotalMatches {
+			return entities, nil
+		}
+	}
+}
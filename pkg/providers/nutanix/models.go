@@ -0,0 +1,95 @@
+package nutanix
+
+// reference is a reference to another entity
+type reference struct {
+	Kind string `json:"kind"`
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type entityMetadata struct {
+	UUID       string            `json:"uuid"`
+	Categories map[string]string `json:"categories"`
+}
+
+// cluster is a Nutanix cluster, or the Prism Central instance itself
+type cluster struct {
+	Metadata entityMetadata `json:"metadata"`
+	Status   struct {
+		Name      string `json:"name"`
+		Resources struct {
+			Config struct {
+				ServiceList []string `json:"service_list"`
+			} `json:"config"`
+		} `json:"resources"`
+	} `json:"status"`
+}
+
+// isPrismCentral returns true if the cluster is the Prism Central VM
+func (c cluster) isPrismCentral() bool {
+	for _, service := range c.Status.Resources.Config.ServiceList {
+		if service == "PRISM_CENTRAL" {
+			return true
+		}
+	}
+	return false
+}
+
+// vm is a Nutanix AHV VM
+type vm struct {
+	Metadata entityMetadata `json:"metadata"`
+	Status   struct {
+		Name             string    `json:"name"`
+		Description      string    `json:"description"`
+		ClusterReference reference `json:"cluster_reference"`
+		Resources        struct {
+			NumSockets        int    `json:"num_sockets"`
+			NumVCPUsPerSocket int    `json:"num_vcpus_per_socket"`
+			MemorySizeMib     int    `json:"memory_size_mib"`
+			PowerState        string `json:"power_state"`
+			DiskList          []disk `json:"disk_list"`
+			NICList           []nic  `json:"nic_list"`
+		} `json:"resources"`
+	} `json:"status"`
+}
+
+type disk struct {
+	UUID             string `json:"uuid"`
+	DiskSizeBytes    int64  `json:"disk_size_bytes"`
+	DiskSizeMib      int64  `json:"disk_size_mib"`
+	DeviceProperties struct {
+		DeviceType  string `json:"device_type"`
+		DiskAddress struct {
+			AdapterType string `json:"adapter_type"`
+			DeviceIndex int    `json:"device_index"`
+		} `json:"disk_address"`
+	} `json:"device_properties"`
+}
+
+type nic struct {
+	UUID           string `json:"uuid"`
+	MacAddress     string `json:"mac_address"`
+	Model          string `json:"model"`
+	VLANMode       string `json:"vlan_mode"`
+	IsConnected    *bool  `json:"is_connected"`
+	IPEndpointList []struct {
+		IP   string `json:"ip"`
+		Type string `json:"type"`
+	} `json:"ip_endpoint_list"`
+	SubnetReference reference `json:"subnet_reference"`
+}
+
+// subnet is a Nutanix network
+type subnet struct {
+	Metadata entityMetadata `json:"metadata"`
+	Status   struct {
+		Name      string `json:"name"`
+		Resources struct {
+			VLANID            int    `json:"vlan_id"`
+			VirtualSwitchName string `json:"virtual_switch_name"`
+			IPConfig          struct {
+				PrefixLength int `json:"prefix_length"`
+			} `json:"ip_config"`
+		} `json:"resources"`
+	} `json:"status"`
+}
@@ -0,0 +1,225 @@
+// Package nutanix syncs the AHV VMs managed by Nutanix Prism Central using
+// the v3 API.
+package nutanix
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*NutanixProvider)(nil)
+
+const mb = 1048576
+const gb = 1073741824
+
+// Option configures the Nutanix provider
+type Option func(*NutanixProvider)
+
+// WithSiteCategory groups the clusters into datacenters by the value of the
+// category assigned to the cluster, eg. a Site or AZ category.  Clusters
+// without the category are in the Prism Central datacenter.
+func WithSiteCategory(category string) Option {
+	return func(n *NutanixProvider) {
+		n.siteCategory = category
+	}
+}
+
+type NutanixProvider struct {
+	client *client
+	log    pkg.Logger
+	// name is the name of the Prism Central datacenter
+	name         string
+	siteCategory string
+	clusters     []cluster
+	// vms and subnets are loaded once and cached for the sync run
+	vms     []vm
+	subnets map[string]subnet
+}
+
+// NewNutanixProvider creates a new VM sync provider for Nutanix Prism
+// Central.  baseURL is the Prism Central URL, eg. https://pc:9440.
+func NewNutanixProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*NutanixProvider, error) {
+	ntx := &NutanixProvider{client: newClient(baseURL, username, password), log: logger}
+	if log, ok := logger.(*slog.Logger); ok {
+		ntx.log = log.With("provider", ntx.GetName())
+	}
+	for _, opt := range opts {
+		opt(ntx)
+	}
+	ntx.name = baseURL
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		ntx.name = u.Hostname()
+	}
+	ntx.log.Info("Connecting...", "url", baseURL)
+	clusters, err := list[cluster](context.Background(), ntx.client, "cluster")
+	if err != nil {
+		return ntx, err
+	}
+	for _, c := range clusters {
+		if !c.isPrismCentral() {
+			ntx.clusters = append(ntx.clusters, c)
+		}
+	}
+	ntx.log.Info("connected to prism central", "clusters", len(ntx.clusters))
+	return ntx, nil
+}
+
+func (ntx *NutanixProvider) GetName() string {
+	return "Nutanix"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider.
+// Prism Central is the datacenter unless the clusters are grouped by their
+// site category.
+func (ntx *NutanixProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	names := make(map[string]bool)
+	for _, c := range ntx.clusters {
+		names[ntx.siteOf(c)] = true
+	}
+	sites := make([]string, 0, len(names))
+	for name := range names {
+		sites = append(sites, name)
+	}
+	sort.Strings(sites)
+	dcs := make([]sync.Datacenter, 0, len(sites))
+	for _, site := range sites {
+		dcs = append(dcs, sync.Datacenter{ID: site, Name: site, Description: "Nutanix Prism Central"})
+	}
+	return dcs, nil
+}
+
+// siteOf returns the datacenter of the cluster
+func (ntx *NutanixProvider) siteOf(c cluster) string {
+	if site := c.Metadata.Categories[ntx.siteCategory]; ntx.siteCategory != "" && site != "" {
+		return site
+	}
+	return ntx.name
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID
+func (ntx *NutanixProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	clusters := make([]sync.Cluster, 0)
+	for _, c := range ntx.clusters {
+		if ntx.siteOf(c) == datacenterID {
+			clusters = append(clusters, sync.Cluster{ID: c.Metadata.UUID, Name: c.Status.Name})
+		}
+	}
+	return clusters, nil
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (ntx *NutanixProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	if err := ntx.load(context.Background()); err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0)
+	for _, v := range ntx.vms {
+		if v.Status.ClusterReference.UUID != clusterID {
+			continue
+		}
+		vms = append(vms, ntx.toVM(v))
+	}
+	return vms, nil
+}
+
+// load retrieves the VMs and subnets of all clusters, unless they have
+// already been loaded
+func (ntx *NutanixProvider) load(ctx context.Context) error {
+	if ntx.vms != nil {
+		return nil
+	}
+	subnets, err := list[subnet](ctx, ntx.client, "subnet")
+	if err != nil {
+		ntx.log.Warn("could not retrieve subnets", "error", err)
+	}
+	ntx.subnets = make(map[string]subnet)
+	for _, s := range subnets {
+		ntx.subnets[s.Metadata.UUID] = s
+	}
+	vms, err := list[vm](ctx, ntx.client, "vm")
+	if err != nil {
+		return fmt.Errorf("could not retrieve VMs: %w", err)
+	}
+	ntx.vms = vms
+	return nil
+}
+
+// toVM converts the Nutanix VM
+func (ntx *NutanixProvider) toVM(v vm) sync.VM {
+	res := v.Status.Resources
+	result := sync.VM{ID: v.Metadata.UUID, Name: v.Status.Name, Type: sync.VMTypeVirtualMachine}
+	result.Description = v.Status.Description
+	// AHV uses the VM UUID as the SMBIOS UUID
+	result.Serial = v.Metadata.UUID
+	result.VCPUs = float32(max(res.NumSockets, 1) * max(res.NumVCPUsPerSocket, 1))
+	result.Memory = res.MemorySizeMib
+	result.PowerState = res.PowerState
+	if res.PowerState == "ON" {
+		result.Status = "active"
+	} else {
+		result.Status = "offline"
+	}
+	result.Metadata = make(map[string]string)
+	for category, value := range v.Metadata.Categories {
+		result.Metadata[category] = value
+	}
+	var diskspace int64
+	for _, d := range res.DiskList {
+		props := d.DeviceProperties
+		if props.DeviceType != "" && props.DeviceType != "DISK" {
+			continue // skip cdroms
+		}
+		name := fmt.Sprintf("%s.%d", strings.ToLower(props.DiskAddress.AdapterType), props.DiskAddress.DeviceIndex)
+		size := d.DiskSizeBytes
+		if size == 0 {
+			size = d.DiskSizeMib * mb
+		}
+		result.Disks = append(result.Disks, sync.Disk{ID: d.UUID, Name: name, Size: size})
+		diskspace += size
+	}
+	result.Diskspace = int(diskspace / gb)
+	result.Network = make([]sync.NIC, 0, len(res.NICList))
+	for idx, n := range res.NICList {
+		result.Network = append(result.Network, ntx.toNIC(idx, n))
+	}
+	return result
+}
+
+// toNIC converts the NIC, adding the VLAN and prefix length of its subnet
+func (ntx *NutanixProvider) toNIC(idx int, n nic) sync.NIC {
+	result := sync.NIC{ID: n.UUID, Name: fmt.Sprintf("nic%d", idx), MAC: strings.ToUpper(n.MacAddress)}
+	result.Type = n.Model
+	result.Network = n.SubnetReference.Name
+	result.Enabled = n.IsConnected
+	result.Tagged = n.VLANMode == "TRUNKED"
+	prefixLength := 0
+	if s, ok := ntx.subnets[n.SubnetReference.UUID]; ok {
+		result.Network = s.Status.Name
+		result.Switch = s.Status.Resources.VirtualSwitchName
+		result.VLAN = s.Status.Resources.VLANID
+		prefixLength = s.Status.Resources.IPConfig.PrefixLength
+	}
+	for _, endpoint := range n.IPEndpointList {
+		ip := net.ParseIP(endpoint.IP)
+		if ip == nil {
+			continue
+		}
+		// The subnet prefix only applies to IPv4 addresses
+		length := 32
+		if ip.To4() == nil {
+			length = 128
+		} else if prefixLength > 0 {
+			length = prefixLength
+		}
+		result.AddIP(fmt.Sprintf("%s/%d", endpoint.IP, length), sync.IPSourceConfig)
+	}
+	return result
+}
@@ -0,0 +1,21 @@
+package nutanix
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "nutanix",
+		Description: "Nutanix AHV through Prism Central, PROVIDER_URL is the Prism Central URL",
+		Settings: []providers.Setting{
+			{Name: "NUTANIX_SITE_CATEGORY", Description: "the cluster category whose value is the Netbox cluster group"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewNutanixProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithSiteCategory(cfg.Get("NUTANIX_SITE_CATEGORY")),
+			)
+		},
+	})
+}
@@ -0,0 +1,193 @@
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// novaMicroversion is the compute API microversion requested.  2.47 embeds
+// the flavor details in the server.
+const novaMicroversion = "2.47"
+
+// client is a minimal Keystone, Nova and Neutron API client
+type client struct {
+	authURL  string
+	credID   string
+	secret   string
+	http     *http.Client
+	token    string
+	catalog  []catalogService
+	expires  time.Time
+	endpoint string
+}
+
+type catalogService struct {
+	Type      string `json:"type"`
+	Endpoints []struct {
+		Interface string `json:"interface"`
+		Region    string `json:"region"`
+		URL       string `json:"url"`
+	} `json:"endpoints"`
+}
+
+func newClient(authURL string, credID string, secret string) *client {
+	return &client{
+		authURL:  strings.TrimSuffix(authURL, "/"),
+		credID:   credID,
+		secret:   secret,
+		endpoint: "public",
+		http: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		},
+	}
+}
+
+// authenticate gets a token for the application credential along with the
+// service catalog
+func (c *client) authenticate(ctx context.Context) error {
+	body := map[string]any{
+		"auth": map[string]any{
+			"identity": map[string]any{
+				"methods": []string{"application_credential"},
+				"application_credential": map[string]string{
+					"id":     c.credID,
+					"secret": c.secret,
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	authURL := c.authURL
+	if !strings.HasSuffix(authURL, "/v3") {
+		authURL += "/v3"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authURL+"/auth/tokens", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("keystone authentication failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	result := struct {
+		Token struct {
+			ExpiresAt time.Time        `json:"expires_at"`
+			Catalog   []catalogService `json:"catalog"`
+		} `json:"token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	c.token = resp.Header.Get("X-Subject-Token")
+	c.catalog = result.Token.Catalog
+	c.expires = result.Token.ExpiresAt
+	return nil
+}
+
+// regions returns the regions that have a compute endpoint
+func (c *client) regions() []string {
+	regions := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, service := range c.catalog {
+		if service.Type != "compute" {
+			continue
+		}
+		for _, ep := range service.Endpoints {
+			if ep.Interface == c.endpoint && !seen[ep.Region] {
+				seen[ep.Region] = true
+				regions = append(regions, ep.Region)
+			}
+		}
+	}
+	return regions
+}
+
+// serviceURL returns the endpoint URL of the service type in the region
+func (c *client) serviceURL(serviceType string, region string) (string, error) {
+	for _, service := range c.catalog {
+		if service.Type != serviceType {
+			continue
+		}
+		for _, ep := range service.Endpoints {
+			if ep.Interface == c.endpoint && ep.Region == region {
+				return strings.TrimSuffix(ep.URL, "/"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s endpoint in region %s", serviceType, region)
+}
+
+// get decodes the response of the URL into result, authenticating again
+// when the token has expired
+func (c *client) get(ctx context.Context, url string, result any) error {
+	if c.token == "" || (!c.expires.IsZero() && time.Now().After(c.expires.Add(-time.Minute))) {
+		if err := c.authenticate(ctx); err != nil {
+			return err
+		}
+	}
+	resp, err := c.doGet(ctx, url)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		if err = c.authenticate(ctx); err != nil {
+			return err
+		}
+		resp, err = c.doGet(ctx, url)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c *client) doGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Auth-Token", c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-OpenStack-Nova-API-Version", novaMicroversion)
+	return c.http.Do(req)
+}
+
+// link is a pagination link of the Nova and Neutron APIs
+type link struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// nextLink returns the href of the next link, or an empty string
+func nextLink(links []link) string {
+	for _, l := range links {
+		if l.Rel == "next" {
+			return l.Href
+		}
+	}
+	return ""
+}
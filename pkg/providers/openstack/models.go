@@ -0,0 +1,108 @@
+package openstack
+
+// server is a Nova server from the servers/detail API
+type server struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Status           string            `json:"status"`
+	TenantID         string            `json:"tenant_id"`
+	Description      *string           `json:"description"`
+	AvailabilityZone string            `json:"OS-EXT-AZ:availability_zone"`
+	Metadata         map[string]string `json:"metadata"`
+	Tags             []string          `json:"tags"`
+	Flavor           struct {
+		ID           string `json:"id"`
+		OriginalName string `json:"original_name"`
+		VCPUs        int    `json:"vcpus"`
+		RAM          int    `json:"ram"`
+		Disk         int    `json:"disk"`
+		Ephemeral    int    `json:"ephemeral"`
+	} `json:"flavor"`
+	VolumesAttached []struct {
+		ID string `json:"id"`
+	} `json:"os-extended-volumes:volumes_attached"`
+}
+
+type serversResponse struct {
+	Servers []server `json:"servers"`
+	Links   []link   `json:"servers_links"`
+}
+
+// flavor is a Nova flavor, used for servers of clouds that do not support
+// the embedded flavor microversion
+type flavor struct {
+	ID        string `json:"id"`
+	VCPUs     int    `json:"vcpus"`
+	RAM       int    `json:"ram"`
+	Disk      int    `json:"disk"`
+	Ephemeral int    `json:"OS-FLV-EXT-DATA:ephemeral"`
+}
+
+// port is a Neutron port
+type port struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	DeviceID     string `json:"device_id"`
+	MACAddress   string `json:"mac_address"`
+	NetworkID    string `json:"network_id"`
+	AdminStateUp bool   `json:"admin_state_up"`
+	FixedIPs     []struct {
+		SubnetID  string `json:"subnet_id"`
+		IPAddress string `json:"ip_address"`
+	} `json:"fixed_ips"`
+}
+
+type portsResponse struct {
+	Ports []port `json:"ports"`
+	Links []link `json:"ports_links"`
+}
+
+type subnet struct {
+	ID   string `json:"id"`
+	CIDR string `json:"cidr"`
+}
+
+type subnetsResponse struct {
+	Subnets []subnet `json:"subnets"`
+	Links   []link   `json:"subnets_links"`
+}
+
+type network struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type networksResponse struct {
+	Networks []network `json:"networks"`
+	Links    []link    `json:"networks_links"`
+}
+
+type floatingIP struct {
+	PortID            *string `json:"port_id"`
+	FloatingIPAddress string  `json:"floating_ip_address"`
+}
+
+type floatingIPsResponse struct {
+	FloatingIPs []floatingIP `json:"floatingips"`
+	Links       []link       `json:"floatingips_links"`
+}
+
+type volume struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+type volumesResponse struct {
+	Volumes []volume `json:"volumes"`
+	Links   []link   `json:"volumes_links"`
+}
+
+type project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type projectsResponse struct {
+	Projects []project `json:"projects"`
+}
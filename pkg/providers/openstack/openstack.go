@@ -0,0 +1,423 @@
+// Package openstack syncs the servers of an OpenStack cloud.  It
+// authenticates with a Keystone application credential and reads the
+// servers, ports and volumes from the Nova, Neutron and Cinder APIs.
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*OpenstackProvider)(nil)
+
+const gb = 1073741824
+
+// Cluster modes decide how servers are grouped into Netbox clusters
+const (
+	// ClusterByZone creates a cluster per availability zone
+	ClusterByZone = "zone"
+	// ClusterByProject creates a cluster per project
+	ClusterByProject = "project"
+)
+
+// Option configures the OpenStack provider
+type Option func(*OpenstackProvider)
+
+// WithClusterMode sets how servers are grouped into clusters
+func WithClusterMode(mode string) Option {
+	return func(o *OpenstackProvider) {
+		if mode != "" {
+			o.clusterMode = strings.ToLower(mode)
+		}
+	}
+}
+
+// WithAllProjects lists the servers of all projects, which requires an
+// admin credential
+func WithAllProjects(all bool) Option {
+	return func(o *OpenstackProvider) {
+		o.allProjects = all
+	}
+}
+
+// WithEndpointInterface sets the catalog endpoint interface to use, eg.
+// internal.  The default is public.
+func WithEndpointInterface(iface string) Option {
+	return func(o *OpenstackProvider) {
+		if iface != "" {
+			o.client.endpoint = iface
+		}
+	}
+}
+
+type OpenstackProvider struct {
+	client      *client
+	log         pkg.Logger
+	clusterMode string
+	allProjects bool
+	projects    map[string]string
+	// servers caches the servers of each region
+	servers map[string][]server
+}
+
+// NewOpenstackProvider creates a new VM sync provider for OpenStack.  baseURL
+// is the Keystone URL, username and password are the application credential
+// ID and secret.
+func NewOpenstackProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*OpenstackProvider, error) {
+	osp := &OpenstackProvider{
+		client:      newClient(baseURL, username, password),
+		log:         logger,
+		clusterMode: ClusterByZone,
+		servers:     make(map[string][]server),
+	}
+	if log, ok := logger.(*slog.Logger); ok {
+		osp.log = log.With("provider", osp.GetName())
+	}
+	for _, opt := range opts {
+		opt(osp)
+	}
+	if osp.clusterMode != ClusterByZone && osp.clusterMode != ClusterByProject {
+		return osp, fmt.Errorf("invalid cluster mode %q, expected %s or %s", osp.clusterMode, ClusterByZone, ClusterByProject)
+	}
+	osp.log.Info("Connecting...", "url", baseURL)
+	if err := osp.client.authenticate(context.Background()); err != nil {
+		return osp, err
+	}
+	osp.log.Info("connected to keystone", "regions", osp.client.regions())
+	osp.projects = osp.loadProjects(context.Background())
+	return osp, nil
+}
+
+func (o *OpenstackProvider) GetName() string {
+	return "OpenStack"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider.
+// Each region with a compute endpoint is a datacenter.
+func (o *OpenstackProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	dcs := make([]sync.Datacenter, 0)
+	for _, region := range o.client.regions() {
+		dcs = append(dcs, sync.Datacenter{ID: region, Name: region, Description: "OpenStack region"})
+	}
+	return dcs, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID.  The
+// clusters are the availability zones or projects of the region's servers.
+func (o *OpenstackProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	servers, err := o.regionServers(context.Background(), datacenterID)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, srv := range servers {
+		key, name := o.clusterOf(srv)
+		names[key] = name
+	}
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	clusters := make([]sync.Cluster, 0, len(keys))
+	for _, key := range keys {
+		clusters = append(clusters, sync.Cluster{ID: clusterID(datacenterID, key), Name: names[key]})
+	}
+	return clusters, nil
+}
+
+// clusterID returns the cluster ID of the zone or project in the region
+func clusterID(region string, key string) string {
+	return region + "/" + key
+}
+
+// clusterOf returns the key and name of the cluster of the server
+func (o *OpenstackProvider) clusterOf(srv server) (string, string) {
+	if o.clusterMode == ClusterByProject {
+		if name, ok := o.projects[srv.TenantID]; ok && name != "" {
+			return srv.TenantID, name
+		}
+		return srv.TenantID, srv.TenantID
+	}
+	zone := srv.AvailabilityZone
+	if zone == "" {
+		zone = "nova"
+	}
+	return zone, zone
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (o *OpenstackProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	ctx := context.Background()
+	region, key, ok := strings.Cut(clusterID, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid cluster ID %s", clusterID)
+	}
+	servers, err := o.regionServers(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	nets, err := o.loadNetworking(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	volumes := o.loadVolumes(ctx, region)
+	flavors := make(map[string]flavor)
+	vms := make([]sync.VM, 0)
+	for _, srv := range servers {
+		if k, _ := o.clusterOf(srv); k != key {
+			continue
+		}
+		vm := sync.VM{ID: srv.ID, Name: srv.Name, Type: sync.VMTypeVirtualMachine}
+		if srv.Description != nil {
+			vm.Description = *srv.Description
+		}
+		vm.PowerState = srv.Status
+		if srv.Status == "ACTIVE" {
+			vm.Status = "active"
+		} else {
+			vm.Status = "offline"
+		}
+		vm.Tags = srv.Tags
+		vm.Metadata = map[string]string{
+			sync.MetaProject: o.projects[srv.TenantID],
+			sync.MetaZone:    srv.AvailabilityZone,
+		}
+		vcpus, ram, disk, ephemeral := srv.Flavor.VCPUs, srv.Flavor.RAM, srv.Flavor.Disk, srv.Flavor.Ephemeral
+		if vcpus == 0 && srv.Flavor.ID != "" {
+			f, err := o.getFlavor(ctx, region, srv.Flavor.ID, flavors)
+			if err != nil {
+				o.log.Warn("could not retrieve flavor", "vm", srv.Name, "flavor", srv.Flavor.ID, "error", err)
+			}
+			vcpus, ram, disk, ephemeral = f.VCPUs, f.RAM, f.Disk, f.Ephemeral
+		}
+		vm.VCPUs = float32(vcpus)
+		vm.Memory = ram
+		if disk > 0 {
+			vm.Disks = append(vm.Disks, sync.Disk{ID: "root", Name: "root", Size: int64(disk) * gb})
+		}
+		if ephemeral > 0 {
+			vm.Disks = append(vm.Disks, sync.Disk{ID: "ephemeral", Name: "ephemeral", Size: int64(ephemeral) * gb})
+		}
+		for _, attached := range srv.VolumesAttached {
+			if vol, ok := volumes[attached.ID]; ok {
+				vm.Disks = append(vm.Disks, sync.Disk{ID: vol.ID, Name: vol.Name, Size: int64(vol.Size) * gb, Description: "volume " + vol.ID})
+			}
+		}
+		for _, d := range vm.Disks {
+			vm.Diskspace += int(d.Size / gb)
+		}
+		vm.Network = nets.serverNICs(srv.ID)
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// regionServers returns the servers of the region, loading them on first use
+func (o *OpenstackProvider) regionServers(ctx context.Context, region string) ([]server, error) {
+	if servers, ok := o.servers[region]; ok {
+		return servers, nil
+	}
+	computeURL, err := o.client.serviceURL("compute", region)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if o.allProjects {
+		query.Set("all_tenants", "1")
+	}
+	servers := make([]server, 0)
+	next := fmt.Sprintf("%s/servers/detail?%s", computeURL, query.Encode())
+	for next != "" {
+		page := serversResponse{}
+		if err := o.client.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		servers = append(servers, page.Servers...)
+		next = nextLink(page.Links)
+	}
+	o.servers[region] = servers
+	return servers, nil
+}
+
+func (o *OpenstackProvider) getFlavor(ctx context.Context, region string, id string, cache map[string]flavor) (flavor, error) {
+	if f, ok := cache[id]; ok {
+		return f, nil
+	}
+	computeURL, err := o.client.serviceURL("compute", region)
+	if err != nil {
+		return flavor{}, err
+	}
+	result := struct {
+		Flavor flavor `json:"flavor"`
+	}{}
+	if err := o.client.get(ctx, fmt.Sprintf("%s/flavors/%s", computeURL, url.PathEscape(id)), &result); err != nil {
+		return flavor{}, err
+	}
+	cache[id] = result.Flavor
+	return result.Flavor, nil
+}
+
+// loadProjects returns the names of the projects the credential can access
+func (o *OpenstackProvider) loadProjects(ctx context.Context) map[string]string {
+	projects := make(map[string]string)
+	authURL := o.client.authURL
+	if !strings.HasSuffix(authURL, "/v3") {
+		authURL += "/v3"
+	}
+	result := projectsResponse{}
+	if err := o.client.get(ctx, authURL+"/auth/projects", &result); err != nil {
+		o.log.Debug("could not retrieve projects", "error", err)
+		return projects
+	}
+	for _, p := range result.Projects {
+		projects[p.ID] = p.Name
+	}
+	return projects
+}
+
+// loadVolumes returns the Cinder volumes of the region by ID
+func (o *OpenstackProvider) loadVolumes(ctx context.Context, region string) map[string]volume {
+	volumes := make(map[string]volume)
+	volumeURL, err := o.client.serviceURL("volumev3", region)
+	if err != nil {
+		o.log.Debug("no block storage endpoint, volume sizes will not be synced", "region", region)
+		return volumes
+	}
+	next := volumeURL + "/volumes/detail"
+	if o.allProjects {
+		next += "?all_tenants=1"
+	}
+	for next != "" {
+		page := volumesResponse{}
+		if err := o.client.get(ctx, next, &page); err != nil {
+			o.log.Warn("could not retrieve volumes", "region", region, "error", err)
+			return volumes
+		}
+		for _, vol := range page.Volumes {
+			volumes[vol.ID] = vol
+		}
+		next = nextLink(page.Links)
+	}
+	return volumes
+}
+
+// networking holds the Neutron ports, subnets, networks and floating IPs
+// of a region
+type networking struct {
+	ports       map[string][]port
+	subnets     map[string]string
+	networks    map[string]string
+	floatingIPs map[string][]string
+}
+
+// loadNetworking reads the ports, subnets, networks and floating IPs of the
+// region
+func (o *OpenstackProvider) loadNetworking(ctx context.Context, region string) (networking, error) {
+	n := networking{
+		ports:       make(map[string][]port),
+		subnets:     make(map[string]string),
+		networks:    make(map[string]string),
+		floatingIPs: make(map[string][]string),
+	}
+	networkURL, err := o.client.serviceURL("network", region)
+	if err != nil {
+		return n, err
+	}
+	networkURL = strings.TrimSuffix(networkURL, "/v2.0") + "/v2.0"
+	for next := networkURL + "/ports"; next != ""; {
+		page := portsResponse{}
+		if err := o.client.get(ctx, next, &page); err != nil {
+			return n, err
+		}
+		for _, p := range page.Ports {
+			if p.DeviceID != "" {
+				n.ports[p.DeviceID] = append(n.ports[p.DeviceID], p)
+			}
+		}
+		next = nextLink(page.Links)
+	}
+	for next := networkURL + "/subnets"; next != ""; {
+		page := subnetsResponse{}
+		if err := o.client.get(ctx, next, &page); err != nil {
+			return n, err
+		}
+		for _, s := range page.Subnets {
+			n.subnets[s.ID] = s.CIDR
+		}
+		next = nextLink(page.Links)
+	}
+	for next := networkURL + "/networks"; next != ""; {
+		page := networksResponse{}
+		if err := o.client.get(ctx, next, &page); err != nil {
+			return n, err
+		}
+		for _, nw := range page.Networks {
+			n.networks[nw.ID] = nw.Name
+		}
+		next = nextLink(page.Links)
+	}
+	for next := networkURL + "/floatingips"; next != ""; {
+		page := floatingIPsResponse{}
+		if err := o.client.get(ctx, next, &page); err != nil {
+			o.log.Debug("could not retrieve floating IPs", "region", region, "error", err)
+			break
+		}
+		for _, fip := range page.FloatingIPs {
+			if fip.PortID != nil && *fip.PortID != "" {
+				n.floatingIPs[*fip.PortID] = append(n.floatingIPs[*fip.PortID], hostAddress(fip.FloatingIPAddress))
+			}
+		}
+		next = nextLink(page.Links)
+	}
+	return n, nil
+}
+
+// serverNICs converts the ports of the server into NICs with their fixed
+// and floating IPs
+func (n networking) serverNICs(serverID string) []sync.NIC {
+	nics := make([]sync.NIC, 0)
+	ports := n.ports[serverID]
+	sort.Slice(ports, func(i, j int) bool { return ports[i].ID < ports[j].ID })
+	for _, p := range ports {
+		nic := sync.NIC{ID: p.ID, Name: p.Name, MAC: strings.ToUpper(p.MACAddress)}
+		if nic.Name == "" {
+			nic.Name = "port-" + p.ID[:min(8, len(p.ID))]
+		}
+		nic.Network = n.networks[p.NetworkID]
+		enabled := p.AdminStateUp
+		nic.Enabled = &enabled
+		nic.IP = make([]string, 0)
+		for _, fixed := range p.FixedIPs {
+			nic.IP = append(nic.IP, withPrefix(fixed.IPAddress, n.subnets[fixed.SubnetID]))
+		}
+		nic.IP = append(nic.IP, n.floatingIPs[p.ID]...)
+		nics = append(nics, nic)
+	}
+	return nics
+}
+
+// withPrefix returns the address with the prefix length of the subnet CIDR
+func withPrefix(ip string, cidr string) string {
+	if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+		ones, _ := ipnet.Mask.Size()
+		return fmt.Sprintf("%s/%d", ip, ones)
+	}
+	return hostAddress(ip)
+}
+
+// hostAddress returns the address as a host route, /32 or /128
+func hostAddress(ip string) string {
+	if strings.Contains(ip, ":") {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
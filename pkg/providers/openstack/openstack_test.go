@@ -0,0 +1,215 @@
+package openstack
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+const (
+	testCredID = "cred-id"
+	testSecret = "cred-secret"
+	testToken  = "token-1"
+)
+
+// newStandIn returns a server standing in for Keystone, Nova, Neutron and
+// Cinder.  The servers and ports are split over two pages.
+func newStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	reply := func(w http.ResponseWriter, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	authorized := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Auth-Token") != testToken {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		}
+	}
+	endpoint := func(iface, url string) map[string]string {
+		return map[string]string{"interface": iface, "region": "RegionOne", "url": url}
+	}
+
+	mux.HandleFunc("POST /v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Auth struct {
+				Identity struct {
+					Methods []string `json:"methods"`
+					Cred    struct {
+						ID     string `json:"id"`
+						Secret string `json:"secret"`
+					} `json:"application_credential"`
+				} `json:"identity"`
+			} `json:"auth"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cred := body.Auth.Identity.Cred
+		if !reflect.DeepEqual(body.Auth.Identity.Methods, []string{"application_credential"}) || cred.ID != testCredID || cred.Secret != testSecret {
+			http.Error(w, "invalid credential", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Subject-Token", testToken)
+		w.WriteHeader(http.StatusCreated)
+		reply(w, map[string]any{"token": map[string]any{
+			"expires_at": "2999-01-01T00:00:00Z",
+			"catalog": []map[string]any{
+				{"type": "compute", "endpoints": []map[string]string{
+					endpoint("public", srv.URL+"/compute/v2.1"),
+					endpoint("internal", "http://internal.invalid/compute"),
+				}},
+				{"type": "network", "endpoints": []map[string]string{endpoint("public", srv.URL+"/network")}},
+				{"type": "volumev3", "endpoints": []map[string]string{endpoint("public", srv.URL+"/volume/v3")}},
+			},
+		}})
+	})
+	mux.HandleFunc("GET /v3/auth/projects", authorized(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"projects": []map[string]string{{"id": "p1", "name": "web"}}})
+	}))
+	mux.HandleFunc("GET /compute/v2.1/servers/detail", authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-OpenStack-Nova-API-Version") != novaMicroversion {
+			http.Error(w, "missing microversion", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("marker") == "" {
+			reply(w, map[string]any{
+				"servers": []map[string]any{{
+					"id": "srv-a", "name": "web1", "status": "ACTIVE", "tenant_id": "p1", "description": "Web server",
+					"OS-EXT-AZ:availability_zone": "az1", "tags": []string{"prod"},
+					"flavor":                               map[string]any{"vcpus": 2, "ram": 4096, "disk": 20, "ephemeral": 0},
+					"os-extended-volumes:volumes_attached": []map[string]string{{"id": "vol-1"}},
+				}},
+				"servers_links": []map[string]string{{"rel": "next", "href": srv.URL + "/compute/v2.1/servers/detail?marker=srv-a"}},
+			})
+			return
+		}
+		reply(w, map[string]any{"servers": []map[string]any{{
+			"id": "srv-b", "name": "db1", "status": "SHUTOFF", "tenant_id": "p1",
+			"OS-EXT-AZ:availability_zone": "az1",
+			"flavor":                      map[string]any{"id": "flv-1"},
+		}}})
+	}))
+	mux.HandleFunc("GET /compute/v2.1/flavors/flv-1", authorized(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"flavor": map[string]any{"id": "flv-1", "vcpus": 4, "ram": 8192, "disk": 40, "OS-FLV-EXT-DATA:ephemeral": 10}})
+	}))
+	mux.HandleFunc("GET /network/v2.0/ports", authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("marker") == "" {
+			reply(w, map[string]any{
+				"ports": []map[string]any{{
+					"id": "port-a1", "name": "", "device_id": "srv-a", "mac_address": "fa:16:3e:00:00:01", "network_id": "net-1", "admin_state_up": true,
+					"fixed_ips": []map[string]string{{"subnet_id": "sub-4", "ip_address": "10.0.0.5"}, {"subnet_id": "sub-6", "ip_address": "2001:db8::5"}},
+				}},
+				"ports_links": []map[string]string{{"rel": "next", "href": srv.URL + "/network/v2.0/ports?marker=port-a1"}},
+			})
+			return
+		}
+		reply(w, map[string]any{"ports": []map[string]any{{
+			"id": "port-b1", "name": "db-port", "device_id": "srv-b", "mac_address": "fa:16:3e:00:00:02", "network_id": "net-1", "admin_state_up": false,
+			"fixed_ips": []map[string]string{{"subnet_id": "sub-unknown", "ip_address": "10.1.0.7"}},
+		}}})
+	}))
+	mux.HandleFunc("GET /network/v2.0/subnets", authorized(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"subnets": []map[string]string{{"id": "sub-4", "cidr": "10.0.0.0/24"}, {"id": "sub-6", "cidr": "2001:db8::/64"}}})
+	}))
+	mux.HandleFunc("GET /network/v2.0/networks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"networks": []map[string]string{{"id": "net-1", "name": "private"}}})
+	}))
+	mux.HandleFunc("GET /network/v2.0/floatingips", authorized(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"floatingips": []map[string]any{
+			{"port_id": "port-a1", "floating_ip_address": "203.0.113.10"},
+			{"port_id": nil, "floating_ip_address": "203.0.113.11"},
+		}})
+	}))
+	mux.HandleFunc("GET /volume/v3/volumes/detail", authorized(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"volumes": []map[string]any{{"id": "vol-1", "name": "data", "size": 100}}})
+	}))
+	return srv
+}
+
+func TestOpenstackProvider(t *testing.T) {
+	srv := newStandIn(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	osp, err := NewOpenstackProvider(srv.URL, testCredID, testSecret, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dcs, err := osp.GetDatacenters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dcs) != 1 || dcs[0].ID != "RegionOne" {
+		t.Fatalf("datacenters %+v, expected RegionOne", dcs)
+	}
+	clusters, err := osp.GetDcClusters("RegionOne")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0].ID != "RegionOne/az1" || clusters[0].Name != "az1" {
+		t.Fatalf("clusters %+v, expected RegionOne/az1", clusters)
+	}
+
+	vms, err := osp.GetClusterVMs("RegionOne/az1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 2 {
+		t.Fatalf("%d VMs, expected the servers of both pages", len(vms))
+	}
+	web, db := vms[0], vms[1]
+	if web.Name != "web1" || web.Status != "active" || web.Description != "Web server" || web.VCPUs != 2 || web.Memory != 4096 {
+		t.Errorf("web1 is %+v", web)
+	}
+	if web.Diskspace != 120 || len(web.Disks) != 2 || web.Disks[1].Name != "data" {
+		t.Errorf("web1 disks %+v, space %d, expected root and the data volume", web.Disks, web.Diskspace)
+	}
+	if web.Metadata[sync.MetaProject] != "web" || web.Metadata[sync.MetaZone] != "az1" || !reflect.DeepEqual(web.Tags, []string{"prod"}) {
+		t.Errorf("web1 metadata %v, tags %v", web.Metadata, web.Tags)
+	}
+	if db.Status != "offline" || db.VCPUs != 4 || db.Memory != 8192 || db.Diskspace != 50 {
+		t.Errorf("db1 is %+v, expected the flavor details", db)
+	}
+
+	if len(web.Network) != 1 {
+		t.Fatalf("web1 NICs %+v", web.Network)
+	}
+	nic := web.Network[0]
+	if nic.Name != "port-port-a1" || nic.MAC != "FA:16:3E:00:00:01" || nic.Network != "private" || nic.Enabled == nil || !*nic.Enabled {
+		t.Errorf("web1 NIC %+v", nic)
+	}
+	if expected := []string{"10.0.0.5/24", "2001:db8::5/64", "203.0.113.10/32"}; !reflect.DeepEqual(nic.IP, expected) {
+		t.Errorf("web1 IPs %v, expected %v", nic.IP, expected)
+	}
+	if len(db.Network) != 1 {
+		t.Fatalf("db1 NICs %+v, expected the port of the second page", db.Network)
+	}
+	nic = db.Network[0]
+	if nic.Name != "db-port" || nic.Enabled == nil || *nic.Enabled {
+		t.Errorf("db1 NIC %+v", nic)
+	}
+	if expected := []string{"10.1.0.7/32"}; !reflect.DeepEqual(nic.IP, expected) {
+		t.Errorf("db1 IPs %v, expected %v", nic.IP, expected)
+	}
+}
+
+func TestOpenstackProviderInvalidCredential(t *testing.T) {
+	srv := newStandIn(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := NewOpenstackProvider(srv.URL, testCredID, "wrong", logger); err == nil {
+		t.Fatal("expected an authentication error")
+	}
+}
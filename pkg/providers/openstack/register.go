@@ -0,0 +1,29 @@
+package openstack
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "openstack",
+		Description: "OpenStack Nova servers, PROVIDER_URL is the Keystone URL",
+		Settings: []providers.Setting{
+			{Name: "OPENSTACK_CLUSTER_MODE", Default: ClusterByZone, Description: "how servers are grouped into Netbox clusters, zone or project"},
+			{Name: "OPENSTACK_ALL_PROJECTS", Default: "false", Description: "sync the servers of all projects, requires an admin role"},
+			{Name: "OPENSTACK_INTERFACE", Default: "public", Description: "the service catalog endpoint interface"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			allProjects, err := cfg.Bool("OPENSTACK_ALL_PROJECTS")
+			if err != nil {
+				return nil, err
+			}
+			return NewOpenstackProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithClusterMode(cfg.Get("OPENSTACK_CLUSTER_MODE")),
+				WithAllProjects(allProjects),
+				WithEndpointInterface(cfg.Get("OPENSTACK_INTERFACE")),
+			)
+		},
+	})
+}
@@ -0,0 +1,123 @@
+package ovirt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// number is a number the oVirt JSON API encodes as a string
+type number int64
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*n = 0
+		return nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	*n = number(i)
+	return err
+}
+
+// boolean is a boolean the oVirt JSON API encodes as a string
+type boolean bool
+
+func (b *boolean) UnmarshalJSON(data []byte) error {
+	*b = strings.Trim(string(data), `"`) == "true"
+	return nil
+}
+
+// link is a reference to another object
+type link struct {
+	ID string `json:"id"`
+}
+
+type dataCenter struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type cluster struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DataCenter  link   `json:"data_center"`
+}
+
+type network struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	VLAN *struct {
+		ID number `json:"id"`
+	} `json:"vlan"`
+}
+
+type vnicProfile struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Network link   `json:"network"`
+}
+
+type vm struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Comment     string `json:"comment"`
+	Status      string `json:"status"`
+	Memory      number `json:"memory"`
+	Cluster     link   `json:"cluster"`
+	CPU         struct {
+		Topology struct {
+			Cores   number `json:"cores"`
+			Sockets number `json:"sockets"`
+			Threads number `json:"threads"`
+		} `json:"topology"`
+	} `json:"cpu"`
+	NICs struct {
+		NIC []nic `json:"nic"`
+	} `json:"nics"`
+	DiskAttachments struct {
+		DiskAttachment []diskAttachment `json:"disk_attachment"`
+	} `json:"disk_attachments"`
+	ReportedDevices struct {
+		ReportedDevice []reportedDevice `json:"reported_device"`
+	} `json:"reported_devices"`
+}
+
+type nic struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	Linked    *boolean `json:"linked"`
+	MAC       struct {
+		Address string `json:"address"`
+	} `json:"mac"`
+	VnicProfile link `json:"vnic_profile"`
+}
+
+type diskAttachment struct {
+	ID        string `json:"id"`
+	Interface string `json:"interface"`
+	Disk      struct {
+		ID              string `json:"id"`
+		Name            string `json:"name"`
+		Alias           string `json:"alias"`
+		Description     string `json:"description"`
+		ProvisionedSize number `json:"provisioned_size"`
+	} `json:"disk"`
+}
+
+// reportedDevice is a network device reported by the guest agent
+type reportedDevice struct {
+	Name string `json:"name"`
+	MAC  struct {
+		Address string `json:"address"`
+	} `json:"mac"`
+	IPs struct {
+		IP []struct {
+			Address string `json:"address"`
+			Version string `json:"version"`
+		} `json:"ip"`
+	} `json:"ips"`
+}
@@ -0,0 +1,221 @@
+// Package ovirt syncs the VMs of oVirt and Red Hat Virtualization using the
+// oVirt REST API.
+package ovirt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*OvirtProvider)(nil)
+
+const mb = 1048576
+const gb = 1073741824
+
+// pageSize is the number of VMs requested per page
+const pageSize = 100
+
+// vmFollow are the links embedded in the VMs of the VM list
+const vmFollow = "nics,disk_attachments.disk,reported_devices"
+
+type OvirtProvider struct {
+	client   *client
+	log      pkg.Logger
+	clusters []cluster
+	// vms are loaded once and cached for the sync run
+	vms []vm
+	// profiles maps vNIC profile IDs to their networks
+	profiles map[string]network
+}
+
+// NewOvirtProvider creates a new VM sync provider for oVirt.  baseURL is the
+// API URL, eg. https://engine/ovirt-engine/api, and username includes the
+// profile, eg. admin@internal.
+func NewOvirtProvider(baseURL string, username string, password string, logger pkg.Logger) (*OvirtProvider, error) {
+	ov := &OvirtProvider{client: newClient(baseURL, username, password), log: logger}
+	if log, ok := logger.(*slog.Logger); ok {
+		ov.log = log.With("provider", ov.GetName())
+	}
+	ov.log.Info("Connecting...", "url", baseURL)
+	result := struct {
+		Cluster []cluster `json:"cluster"`
+	}{}
+	if err := ov.client.get(context.Background(), "/clusters", &result); err != nil {
+		return ov, err
+	}
+	ov.clusters = result.Cluster
+	ov.log.Info("connected to ovirt", "clusters", len(ov.clusters))
+	return ov, nil
+}
+
+func (ov *OvirtProvider) GetName() string {
+	return "oVirt"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider
+func (ov *OvirtProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	result := struct {
+		DataCenter []dataCenter `json:"data_center"`
+	}{}
+	if err := ov.client.get(context.Background(), "/datacenters", &result); err != nil {
+		return nil, err
+	}
+	dcs := make([]sync.Datacenter, 0, len(result.DataCenter))
+	for _, dc := range result.DataCenter {
+		dcs = append(dcs, sync.Datacenter{ID: dc.ID, Name: dc.Name, Description: dc.Description})
+	}
+	return dcs, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID
+func (ov *OvirtProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	clusters := make([]sync.Cluster, 0)
+	for _, c := range ov.clusters {
+		if c.DataCenter.ID == datacenterID {
+			clusters = append(clusters, sync.Cluster{ID: c.ID, Name: c.Name, Description: c.Description})
+		}
+	}
+	return clusters, nil
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (ov *OvirtProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	if err := ov.load(context.Background()); err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0)
+	for _, v := range ov.vms {
+		if v.Cluster.ID == clusterID {
+			vms = append(vms, ov.toVM(v))
+		}
+	}
+	return vms, nil
+}
+
+// load retrieves the VMs of all clusters a page at a time, along with the
+// networks of the vNIC profiles, unless they have already been loaded
+func (ov *OvirtProvider) load(ctx context.Context) error {
+	if ov.vms != nil {
+		return nil
+	}
+	ov.profiles = ov.loadProfiles(ctx)
+	vms := make([]vm, 0)
+	for page := 1; ; page++ {
+		query := url.Values{
+			"search": {fmt.Sprintf("sortby name asc page %d", page)},
+			"max":    {fmt.Sprint(pageSize)},
+			"follow": {vmFollow},
+		}
+		result := struct {
+			VM []vm `json:"vm"`
+		}{}
+		if err := ov.client.get(ctx, "/vms?"+query.Encode(), &result); err != nil {
+			return fmt.Errorf("could not retrieve VMs: %w", err)
+		}
+		vms = append(vms, result.VM...)
+		if len(result.VM) < pageSize {
+			break
+		}
+	}
+	ov.vms = vms
+	return nil
+}
+
+// loadProfiles returns the networks of the vNIC profiles by profile ID
+func (ov *OvirtProvider) loadProfiles(ctx context.Context) map[string]network {
+	profiles := make(map[string]network)
+	networks := struct {
+		Network []network `json:"network"`
+	}{}
+	if err := ov.client.get(ctx, "/networks", &networks); err != nil {
+		ov.log.Warn("could not retrieve networks", "error", err)
+		return profiles
+	}
+	byID := make(map[string]network)
+	for _, n := range networks.Network {
+		byID[n.ID] = n
+	}
+	result := struct {
+		VnicProfile []vnicProfile `json:"vnic_profile"`
+	}{}
+	if err := ov.client.get(ctx, "/vnicprofiles", &result); err != nil {
+		ov.log.Warn("could not retrieve vnic profiles", "error", err)
+		return profiles
+	}
+	for _, p := range result.VnicProfile {
+		profiles[p.ID] = byID[p.Network.ID]
+	}
+	return profiles
+}
+
+// toVM converts the oVirt VM
+func (ov *OvirtProvider) toVM(v vm) sync.VM {
+	result := sync.VM{ID: v.ID, Name: v.Name, Type: sync.VMTypeVirtualMachine}
+	result.Description = v.Description
+	if result.Description == "" {
+		result.Description = v.Comment
+	}
+	// oVirt uses the VM ID as the SMBIOS UUID
+	result.Serial = v.ID
+	topology := v.CPU.Topology
+	result.VCPUs = float32(max(topology.Cores, 1) * max(topology.Sockets, 1) * max(topology.Threads, 1))
+	result.Memory = int(v.Memory / mb)
+	result.PowerState = v.Status
+	if v.Status == "up" {
+		result.Status = "active"
+	} else {
+		result.Status = "offline"
+	}
+	var diskspace int64
+	for _, attachment := range v.DiskAttachments.DiskAttachment {
+		d := attachment.Disk
+		name := d.Alias
+		if name == "" {
+			name = d.Name
+		}
+		size := int64(d.ProvisionedSize)
+		result.Disks = append(result.Disks, sync.Disk{ID: d.ID, Name: name, Size: size, Description: d.Description})
+		diskspace += size
+	}
+	result.Diskspace = int(diskspace / gb)
+	result.Network = make([]sync.NIC, 0, len(v.NICs.NIC))
+	for _, n := range v.NICs.NIC {
+		nic := sync.NIC{ID: n.ID, Name: n.Name, MAC: strings.ToUpper(n.MAC.Address)}
+		nic.Type = n.Interface
+		if n.Linked != nil {
+			linked := bool(*n.Linked)
+			nic.Enabled = &linked
+		}
+		if nw, ok := ov.profiles[n.VnicProfile.ID]; ok {
+			nic.Network = nw.Name
+			if nw.VLAN != nil {
+				nic.VLAN = int(nw.VLAN.ID)
+			}
+		}
+		for _, device := range v.ReportedDevices.ReportedDevice {
+			if !strings.EqualFold(device.MAC.Address, n.MAC.Address) {
+				continue
+			}
+			for _, ip := range device.IPs.IP {
+				nic.AddIP(hostAddress(ip.Address), sync.IPSourceAgent)
+			}
+		}
+		result.Network = append(result.Network, nic)
+	}
+	return result
+}
+
+// hostAddress returns the address as a host route, /32 or /128
+func hostAddress(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
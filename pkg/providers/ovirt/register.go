@@ -0,0 +1,16 @@
+package ovirt
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "ovirt",
+		Description: "oVirt and Red Hat Virtualization, PROVIDER_URL is the engine API URL",
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewOvirtProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger)
+		},
+	})
+}
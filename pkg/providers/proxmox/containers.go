@@ -0,0 +1,84 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+
+	proxapi "github.com/luthermonson/go-proxmox"
+	"github.com/ringsq/netboxvmsync/pkg/providers/proxmoxcfg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// lxcType is the cluster resource type of LXC containers
+const lxcType = "lxc"
+
+// getContainer converts the LXC container resource into a VM using the
+// container config and the interfaces reported by the running container
+func (p *ProxmoxProvider) getContainer(ctx context.Context, resource *proxapi.ClusterResource) sync.VM {
+	vm := sync.VM{}
+	vm.ID = fmt.Sprint(resource.VMID)
+	vm.Name = resource.Name
+	vm.Type = sync.VMTypeContainer
+	vm.Memory = int(resource.MaxMem / mb)
+	vm.Diskspace = int(resource.MaxDisk / gb)
+	vm.VCPUs = float32(resource.MaxCPU)
+	vm.PowerState = resource.Status
+	if resource.Status == "running" {
+		vm.Status = "active"
+	} else {
+		vm.Status = "offline"
+	}
+	vm.Network = make([]sync.NIC, 0)
+
+	cfg := make(map[string]interface{})
+	if err := p.get(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/config", resource.Node, resource.VMID), &cfg); err != nil {
+		p.log.Warn("could not retrieve container config", "vm", vm.Name, "error", err)
+		return vm
+	}
+	interfaces := make([]proxmoxcfg.ContainerInterface, 0)
+	if resource.Status == "running" {
+		if err := p.get(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/interfaces", resource.Node, resource.VMID), &interfaces); err != nil {
+			p.log.Debug("could not retrieve container interfaces", "vm", vm.Name, "error", err)
+		}
+	}
+
+	guest, err := proxmoxcfg.Parse(proxmoxcfg.New(cfg))
+	if err != nil {
+		p.log.Warn("could not parse all of the container config", "vm", vm.Name, "error", err)
+	}
+	vm.Description = guest.Description
+	if guest.Memory.Memory > 0 {
+		vm.Memory = guest.Memory.Memory
+	}
+	if _, ok := cfg["cores"]; ok {
+		vm.VCPUs = float32(guest.CPU.Cores)
+	}
+	vm.Disks = guest.VMDisks()
+	if size := guest.DiskSize(); size > 0 {
+		vm.Diskspace = int(size / gb)
+	}
+	for _, net := range guest.Nets {
+		vm.Network = append(vm.Network, containerNIC(net, interfaces))
+	}
+	return vm
+}
+
+// containerNIC converts the netX config entry of a container into a NIC,
+// adding the addresses reported by the running container
+func containerNIC(net proxmoxcfg.NetDevice, interfaces []proxmoxcfg.ContainerInterface) sync.NIC {
+	nic := sync.NIC{ID: net.Key, Name: net.Key, Description: net.Raw}
+	if net.Name != "" {
+		nic.Name = net.Name
+	}
+	nic.MAC = net.MAC
+	nic.IP = make([]string, 0)
+	for _, ip := range net.Addresses() {
+		nic.AddIP(ip, sync.IPSourceConfig)
+	}
+	if intf, found := proxmoxcfg.FindContainerInterface(interfaces, nic.MAC); found {
+		for _, ip := range intf.Addresses() {
+			nic.AddIP(ip, sync.IPSourceAgent)
+		}
+	}
+	return nic
+}
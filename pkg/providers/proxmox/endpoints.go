@@ -0,0 +1,86 @@
+package proxmox
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	proxapi "github.com/luthermonson/go-proxmox"
+)
+
+// splitEndpoints returns the comma separated URLs of the cluster nodes
+func splitEndpoints(baseURL string) []string {
+	endpoints := make([]string, 0)
+	for _, endpoint := range strings.Split(baseURL, ",") {
+		endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// connect connects to the first endpoint that responds, starting with the
+// given endpoint index
+func (p *ProxmoxProvider) connect(ctx context.Context, start int) error {
+	insecureHTTPClient := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	var errs []error
+	for i := range p.endpoints {
+		idx := (start + i) % len(p.endpoints)
+		client := proxapi.NewClient(fmt.Sprintf("%s/api2/json", p.endpoints[idx]),
+			proxapi.WithHTTPClient(&insecureHTTPClient),
+			proxapi.WithAPIToken(p.username, p.password),
+		)
+		version, err := client.Version(ctx)
+		if err != nil {
+			p.log.Warn("could not connect to proxmox", "url", p.endpoints[idx], "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.endpoints[idx], err))
+			continue
+		}
+		p.client = client
+		p.endpoint = idx
+		p.log.Info("connected to proxmox", "url", p.endpoints[idx], "version", version)
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// withFailover runs the call and, if the current endpoint cannot be
+// reached, connects to the next endpoint and runs it again
+func (p *ProxmoxProvider) withFailover(ctx context.Context, call func() error) error {
+	err := call()
+	if err == nil || !isConnectionError(err) || len(p.endpoints) < 2 {
+		return err
+	}
+	p.log.Warn("proxmox endpoint is down, failing over", "url", p.endpoints[p.endpoint], "error", err)
+	if cerr := p.connect(ctx, p.endpoint+1); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return call()
+}
+
+// get runs a GET request of the API through withFailover
+func (p *ProxmoxProvider) get(ctx context.Context, path string, v interface{}) error {
+	return p.withFailover(ctx, func() error {
+		return p.client.Get(ctx, path, v)
+	})
+}
+
+// isConnectionError returns true if the error means the endpoint could not
+// be reached, as opposed to an error returned by the API
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
@@ -0,0 +1,50 @@
+package proxmox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Standalone modes decide how nodes that are not part of a Proxmox cluster
+// are mapped to Netbox clusters
+const (
+	// StandaloneNode creates one Netbox cluster per node, named after the node
+	StandaloneNode = "node"
+	// StandaloneCluster puts all nodes in one Netbox cluster with the name
+	// given by WithClusterName
+	StandaloneCluster = "cluster"
+)
+
+// Option configures the Proxmox provider
+type Option func(*ProxmoxProvider)
+
+// WithStandaloneMode sets how standalone nodes are mapped to Netbox clusters
+func WithStandaloneMode(mode string) Option {
+	return func(p *ProxmoxProvider) {
+		if mode != "" {
+			p.standaloneMode = strings.ToLower(mode)
+		}
+	}
+}
+
+// WithClusterName sets the Netbox cluster name used for standalone nodes in
+// the StandaloneCluster mode
+func WithClusterName(name string) Option {
+	return func(p *ProxmoxProvider) {
+		p.clusterName = name
+	}
+}
+
+// validate checks the options after they are applied
+func (p *ProxmoxProvider) validate() error {
+	switch p.standaloneMode {
+	case StandaloneNode:
+	case StandaloneCluster:
+		if p.clusterName == "" {
+			return fmt.Errorf("a cluster name is required for the %s standalone mode", StandaloneCluster)
+		}
+	default:
+		return fmt.Errorf("invalid standalone mode %q, expected %s or %s", p.standaloneMode, StandaloneNode, StandaloneCluster)
+	}
+	return nil
+}
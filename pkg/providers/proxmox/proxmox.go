@@ -2,15 +2,15 @@ package proxmox
 
 import (
 	"context"
-	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"strings"
 
 	proxapi "github.com/luthermonson/go-proxmox"
 	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/providers/proxmoxcfg"
 	"github.com/ringsq/netboxvmsync/pkg/sync"
 )
 
@@ -22,33 +22,45 @@ const mb = 1048576
 type ProxmoxProvider struct {
 	client *proxapi.Client
 	log    pkg.Logger
+	// endpoints are the URLs of the cluster nodes, endpoint is the index of
+	// the one in use
+	endpoints      []string
+	endpoint       int
+	username       string
+	password       string
+	standaloneMode string
+	clusterName    string
 }
 
 var ErrNotImplemented = errors.New("not implemented")
 
-// NewVmwareProvider creates a new VM sync provider using vmware vcenter
-func NewProxmoxProvider(baseURL string, username string, password string, logger pkg.Logger) (*ProxmoxProvider, error) {
-	insecureHTTPClient := http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-	}
-	prox := &ProxmoxProvider{log: logger}
+// NewVmwareProvider creates a new VM sync provider using vmware vcenter.
+// baseURL may hold several comma separated node URLs of the same cluster,
+// which are tried in order.
+func NewProxmoxProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*ProxmoxProvider, error) {
+	prox := &ProxmoxProvider{
+		log:            logger,
+		endpoints:      splitEndpoints(baseURL),
+		username:       username,
+		password:       password,
+		standaloneMode: StandaloneNode,
+	}
 	if log, ok := logger.(*slog.Logger); ok {
 		prox.log = log.With("provider", prox.GetName())
 	}
+	for _, opt := range opts {
+		opt(prox)
+	}
+	if err := prox.validate(); err != nil {
+		return prox, err
+	}
+	if len(prox.endpoints) == 0 {
+		return prox, errors.New("no proxmox URL given")
+	}
 	prox.log.Info("Connecting...", "user", username)
-	prox.client = proxapi.NewClient(fmt.Sprintf("%s/api2/json", baseURL),
-		proxapi.WithHTTPClient(&insecureHTTPClient),
-		proxapi.WithAPIToken(username, password),
-	)
-	version, err := prox.client.Version(context.Background())
-	if err != nil {
+	if err := prox.connect(context.Background(), 0); err != nil {
 		return prox, err
 	}
-	prox.log.Info("connected to proxmox", "version", version)
 	return prox, nil
 }
 
@@ -65,71 +77,143 @@ func (p *ProxmoxProvider) GetDatacenters() ([]sync.Datacenter, error) {
 	return []sync.Datacenter{dc}, nil
 }
 
-// GetDcClusters gets a list of clusters for the given datacenter ID
+// GetDcClusters gets a list of clusters for the given datacenter ID.  Nodes
+// that are not part of a cluster are mapped according to the standalone mode.
 func (p *ProxmoxProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
-	pCluster, err := p.client.Cluster(context.Background())
+	ctx := context.Background()
+	var pCluster *proxapi.Cluster
+	err := p.withFailover(ctx, func() (err error) {
+		pCluster, err = p.client.Cluster(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	cluster := sync.Cluster{Name: pCluster.Name, ID: pCluster.ID}
-	return []sync.Cluster{cluster}, nil
+	if pCluster.Name != "" {
+		cluster := sync.Cluster{Name: pCluster.Name, ID: pCluster.ID}
+		return []sync.Cluster{cluster}, nil
+	}
+	if p.standaloneMode == StandaloneCluster {
+		p.log.Info("proxmox node is not clustered, using the configured cluster name", "cluster", p.clusterName)
+		return []sync.Cluster{{Name: p.clusterName, ID: p.clusterName}}, nil
+	}
+	var nodes proxapi.NodeStatuses
+	err = p.withFailover(ctx, func() (err error) {
+		nodes, err = p.client.Nodes(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	clusters := make([]sync.Cluster, 0, len(nodes))
+	for _, node := range nodes {
+		p.log.Info("proxmox node is not clustered, mapping it to its own cluster", "node", node.Node)
+		clusters = append(clusters, sync.Cluster{Name: node.Node, ID: nodeClusterPrefix + node.Node})
+	}
+	return clusters, nil
 }
 
+// nodeClusterPrefix marks the cluster IDs of standalone nodes
+// in the StandaloneNode mode
+const nodeClusterPrefix = "node/"
+
 // GetClusterVMs returns a list of VMs for the given cluster ID
 func (p *ProxmoxProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 	ctx := context.Background()
-	cluster, err := p.client.Cluster(ctx)
-	if err != nil {
-		return nil, err
-	}
-	clusterRes, err := cluster.Resources(ctx, "vm")
+	var clusterRes proxapi.ClusterResources
+	err := p.withFailover(ctx, func() error {
+		cluster, err := p.client.Cluster(ctx)
+		if err != nil {
+			return err
+		}
+		clusterRes, err = cluster.Resources(ctx, "vm")
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	nodeName, standalone := strings.CutPrefix(clusterID, nodeClusterPrefix)
+	haResources := p.haResources(ctx)
 	vms := make([]sync.VM, 0)
 	for _, resource := range clusterRes {
 		if resource.Template == 1 { // skip templates
 			continue
 		}
+		if standalone && resource.Node != nodeName {
+			continue
+		}
+		metadata := proxmoxcfg.Metadata(resource.Pool, resource.HAstate, haResources[int(resource.VMID)])
+		if resource.Type == lxcType {
+			vm := p.getContainer(ctx, resource)
+			vm.Metadata = metadata
+			vms = append(vms, vm)
+			continue
+		}
 		vm := sync.VM{}
 		vm.ID = fmt.Sprint(resource.VMID)
 		vm.Name = resource.Name
-		vm.Description = resource.Type
+		vm.Type = sync.VMTypeVirtualMachine
+		vm.Metadata = metadata
 		vm.Memory = int(resource.MaxMem / mb)
 		vm.Diskspace = int(resource.MaxDisk / gb)
 		vm.VCPUs = float32(resource.MaxCPU)
+		vm.PowerState = resource.Status
 		if resource.Status == "running" {
 			vm.Status = "active"
 		} else {
 			vm.Status = "offline"
 		}
-		node, err := p.client.Node(ctx, resource.Node)
+		var pVM *proxapi.VirtualMachine
+		err := p.withFailover(ctx, func() error {
+			node, err := p.client.Node(ctx, resource.Node)
+			if err != nil {
+				return err
+			}
+			pVM, err = node.VirtualMachine(ctx, int(resource.VMID))
+			return err
+		})
 		if err != nil {
-			p.log.Warn("could not retrieve node for VM", "vm", vm.Name, "error", err)
+			p.log.Warn("could not retrieve VM details", "vm", vm.Name, "error", err)
 			vms = append(vms, vm)
 			continue
 		}
-		pVM, err := node.VirtualMachine(ctx, int(resource.VMID))
+		if pVM.QMPStatus != "" {
+			vm.PowerState = pVM.QMPStatus
+		}
+		guest, err := guestConfig(pVM.VirtualMachineConfig)
 		if err != nil {
-			p.log.Warn("could not retrieve VM details", "vm", vm.Name, "error", err)
-			vms = append(vms, vm)
-			continue
+			p.log.Warn("could not parse all of the VM config", "vm", vm.Name, "error", err)
+		}
+		if guest.Memory.Memory > 0 {
+			vm.Memory = guest.Memory.Memory
+		}
+		vm.Description = guest.Description
+		vm.Serial = guest.SMBIOS.UUID
+		vm.Disks = guest.VMDisks()
+		if size := guest.DiskSize(); size > 0 {
+			vm.Diskspace = int(size / gb)
 		}
-		vm.Memory = int(pVM.VirtualMachineConfig.Memory)
-		vm.Description = pVM.VirtualMachineConfig.Description
 		vm.Network = make([]sync.NIC, 0)
-		agentIFs, _ := pVM.AgentGetNetworkIFaces(ctx)
-		nets := pVM.VirtualMachineConfig.MergeNets()
-		for nicName, details := range nets {
-			nicDetail := splitFieldValue(details)
-			nic := sync.NIC{ID: nicName, Name: nicName}
-			nic.MAC = nicDetail["virtio"]
-			nic.Description = details
-			if agentIF, found := findAgentIF(agentIFs, nic.MAC); found {
+		agent := proxmoxcfg.AgentResult{}
+		if resource.Status == "running" {
+			if err := p.get(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/agent/network-get-interfaces", resource.Node, resource.VMID), &agent); err != nil {
+				p.log.Debug("could not retrieve guest agent interfaces", "vm", vm.Name, "error", err)
+			}
+		}
+		for _, net := range guest.Nets {
+			nic := sync.NIC{ID: net.Key, Name: net.Key}
+			nic.MAC = net.MAC
+			nic.Description = net.Raw
+			nic.IP = make([]string, 0)
+			if ipconfig, ok := guest.IPConfigs[net.Index]; ok {
+				for _, ip := range ipconfig.Addresses() {
+					nic.AddIP(ip, sync.IPSourceCloudInit)
+				}
+			}
+			if agentIF, found := proxmoxcfg.FindAgentInterface(agent.Result, nic.MAC); found {
 				nic.Name = agentIF.Name
-				nic.IP = make([]string, 0)
-				for _, pIP := range agentIF.IPAddresses {
-					nic.IP = append(nic.IP, fmt.Sprintf("%s/%d", pIP.IPAddress, pIP.Prefix))
+				for _, ip := range agentIF.Addresses() {
+					nic.AddIP(ip, sync.IPSourceAgent)
 				}
 			}
 			vm.Network = append(vm.Network, nic)
@@ -139,25 +223,27 @@ func (p *ProxmoxProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 	return vms, nil
 }
 
-func findAgentIF(agentIFs []*proxapi.AgentNetworkIface, mac string) (*proxapi.AgentNetworkIface, bool) {
-	for _, intf := range agentIFs {
-		if strings.EqualFold(intf.HardwareAddress, mac) {
-			return intf, true
-		}
+// haResources returns the HA resources of the cluster indexed by guest ID
+func (p *ProxmoxProvider) haResources(ctx context.Context) map[int]proxmoxcfg.HAResource {
+	resources := make([]proxmoxcfg.HAResource, 0)
+	if err := p.get(ctx, "/cluster/ha/resources", &resources); err != nil {
+		p.log.Debug("could not retrieve HA resources", "error", err)
 	}
-	return nil, false
+	return proxmoxcfg.HAResourcesByVMID(resources)
 }
 
-// splitFieldValues takes the field of the form key=value,key=value
-// and returns it as a map of [key]value
-func splitFieldValue(field string) map[string]string {
-	data := make(map[string]string)
-	fields := strings.Split(field, ",")
-	for _, field := range fields {
-		values := strings.Split(field, "=")
-		if len(values) == 2 {
-			data[values[0]] = values[1]
-		}
+// guestConfig parses the VM config using the shared Proxmox config parser
+func guestConfig(vmc *proxapi.VirtualMachineConfig) (proxmoxcfg.GuestConfig, error) {
+	if vmc == nil {
+		return proxmoxcfg.GuestConfig{}, errors.New("VM has no config")
+	}
+	data, err := json.Marshal(vmc)
+	if err != nil {
+		return proxmoxcfg.GuestConfig{}, err
+	}
+	raw := make(map[string]interface{})
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return proxmoxcfg.GuestConfig{}, err
 	}
-	return data
+	return proxmoxcfg.Parse(proxmoxcfg.New(raw))
 }
@@ -0,0 +1,23 @@
+package proxmox
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "proxmox",
+		Description: "Proxmox VE cluster or standalone nodes, PROVIDER_URL lists node URLs",
+		Settings: []providers.Setting{
+			{Name: "PROXMOX_STANDALONE", Default: StandaloneNode, Description: "how standalone nodes are mapped to Netbox clusters, node or cluster"},
+			{Name: "PROXMOX_CLUSTER_NAME", Description: "the Netbox cluster of standalone nodes in the cluster mode"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewProxmoxProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithStandaloneMode(cfg.Get("PROXMOX_STANDALONE")),
+				WithClusterName(cfg.Get("PROXMOX_CLUSTER_NAME")),
+			)
+		},
+	})
+}
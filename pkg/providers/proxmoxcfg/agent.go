@@ -0,0 +1,84 @@
+package proxmoxcfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AgentResult is the response of the QEMU guest agent
+// network-get-interfaces API
+type AgentResult struct {
+	Result []AgentInterface `json:"result"`
+}
+
+// AgentInterface is a network interface reported by the QEMU guest agent
+type AgentInterface struct {
+	Name        string           `json:"name"`
+	MAC         string           `json:"hardware-address"`
+	IPAddresses []AgentIPAddress `json:"ip-addresses"`
+}
+
+// AgentIPAddress is an address of an interface reported by the QEMU guest agent
+type AgentIPAddress struct {
+	Address string `json:"ip-address"`
+	Type    string `json:"ip-address-type"`
+	Prefix  int    `json:"prefix"`
+}
+
+// Addresses returns the addresses of the interface in CIDR notation
+func (a AgentInterface) Addresses() []string {
+	ips := make([]string, 0, len(a.IPAddresses))
+	for _, ip := range a.IPAddresses {
+		if ip.Address == "" {
+			continue
+		}
+		ips = append(ips, fmt.Sprintf("%s/%d", ip.Address, ip.Prefix))
+	}
+	return ips
+}
+
+// FindAgentInterface returns the agent interface with the MAC address
+func FindAgentInterface(interfaces []AgentInterface, mac string) (AgentInterface, bool) {
+	if mac == "" {
+		return AgentInterface{}, false
+	}
+	for _, intf := range interfaces {
+		if strings.EqualFold(intf.MAC, mac) {
+			return intf, true
+		}
+	}
+	return AgentInterface{}, false
+}
+
+// ContainerInterface is a network interface reported by a running LXC
+// container
+type ContainerInterface struct {
+	Name   string `json:"name"`
+	HWAddr string `json:"hwaddr"`
+	Inet   string `json:"inet"`
+	Inet6  string `json:"inet6"`
+}
+
+// Addresses returns the addresses of the interface in CIDR notation
+func (c ContainerInterface) Addresses() []string {
+	ips := make([]string, 0, 2)
+	for _, ip := range []string{c.Inet, c.Inet6} {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// FindContainerInterface returns the container interface with the MAC address
+func FindContainerInterface(interfaces []ContainerInterface, mac string) (ContainerInterface, bool) {
+	if mac == "" {
+		return ContainerInterface{}, false
+	}
+	for _, intf := range interfaces {
+		if strings.EqualFold(intf.HWAddr, mac) {
+			return intf, true
+		}
+	}
+	return ContainerInterface{}, false
+}
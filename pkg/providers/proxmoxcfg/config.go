@@ -0,0 +1,243 @@
+// Package proxmoxcfg parses the guest config of Proxmox VMs and LXC
+// containers into typed structs.  It is shared by the Proxmox VE and the
+// Proxmox Datacenter Manager providers.
+package proxmoxcfg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// Config is the raw key/value config of a Proxmox guest
+// as returned by the qemu/{vmid}/config and lxc/{vmid}/config APIs
+type Config map[string]string
+
+// New converts the decoded JSON config into a Config
+func New(raw map[string]interface{}) Config {
+	cfg := make(Config, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			cfg[key] = v
+		case float64:
+			cfg[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			cfg[key] = fmt.Sprint(v)
+		}
+	}
+	return cfg
+}
+
+// GuestConfig is the parsed config of a VM or container
+type GuestConfig struct {
+	Name        string
+	Description string
+	OSType      string
+	Tags        []string
+	CPU         CPU
+	Memory      Memory
+	Nets        []NetDevice
+	IPConfigs   map[int]IPConfig
+	Disks       []Disk
+	Mounts      []Mount
+	SMBIOS      SMBIOS
+}
+
+var (
+	netKey      = regexp.MustCompile(`^net(\d+)$`)
+	ipconfigKey = regexp.MustCompile(`^ipconfig(\d+)$`)
+	diskKey     = regexp.MustCompile(`^(scsi|virtio|sata|ide)(\d+)$`)
+	mountKey    = regexp.MustCompile(`^(rootfs|mp\d+)$`)
+)
+
+// Parse parses every known entry of the config.  Values that cannot be
+// parsed are left empty and reported in the returned error, so the config
+// is usable even if err is not nil.
+func Parse(cfg Config) (GuestConfig, error) {
+	guest := GuestConfig{IPConfigs: make(map[int]IPConfig)}
+	var errs []error
+	addErr := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	var err error
+
+	guest.Name = cfg["name"]
+	if guest.Name == "" {
+		guest.Name = cfg["hostname"]
+	}
+	guest.Description = cfg["description"]
+	guest.OSType = cfg["ostype"]
+	guest.Tags = ParseTags(cfg["tags"])
+	guest.CPU, err = ParseCPU(cfg)
+	addErr("cpu", err)
+	guest.Memory, err = ParseMemory(cfg)
+	addErr("memory", err)
+	if smbios, ok := cfg["smbios1"]; ok {
+		guest.SMBIOS, err = ParseSMBIOS(smbios)
+		addErr("smbios1", err)
+	}
+
+	// Sort the keys so devices are returned in a stable order
+	keys := make([]string, 0, len(cfg))
+	for key := range cfg {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := cfg[key]
+		switch {
+		case netKey.MatchString(key):
+			net, err := ParseNet(key, value)
+			addErr(key, err)
+			guest.Nets = append(guest.Nets, net)
+		case ipconfigKey.MatchString(key):
+			idx, _ := strconv.Atoi(ipconfigKey.FindStringSubmatch(key)[1])
+			guest.IPConfigs[idx] = ParseIPConfig(value)
+		case diskKey.MatchString(key):
+			disk, err := ParseDisk(key, value)
+			addErr(key, err)
+			guest.Disks = append(guest.Disks, disk)
+		case mountKey.MatchString(key):
+			mount, err := ParseMount(key, value)
+			addErr(key, err)
+			guest.Mounts = append(guest.Mounts, mount)
+		}
+	}
+	return guest, errors.Join(errs...)
+}
+
+// Properties is a Proxmox property string of the form
+// [default,]key=value,key=value
+type Properties struct {
+	// Default is the value given without a key, eg. the volume of a disk
+	Default string
+	Values  map[string]string
+	// Order holds the keys in the order they were given
+	Order []string
+}
+
+// ParseProperties splits the property string into its values.  Entries
+// without a key are stored as the Default value.
+func ParseProperties(value string) Properties {
+	props := Properties{Values: make(map[string]string)}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(entry, "=")
+		if !ok {
+			if props.Default == "" {
+				props.Default = entry
+			}
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, exists := props.Values[key]; !exists {
+			props.Order = append(props.Order, key)
+		}
+		props.Values[key] = strings.TrimSpace(val)
+	}
+	return props
+}
+
+// Get returns the value of the key
+func (p Properties) Get(key string) string {
+	return p.Values[key]
+}
+
+// Bool returns true if the value of the key is 1
+func (p Properties) Bool(key string) bool {
+	return p.Values[key] == "1"
+}
+
+// Int returns the integer value of the key, or 0 if it is not set
+func (p Properties) Int(key string) (int, error) {
+	value, ok := p.Values[key]
+	if !ok || value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return i, nil
+}
+
+// ParseTags splits the tags, which Proxmox separates by ; , or spaces
+func ParseTags(value string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	}) {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ParseSize converts a Proxmox size (eg. 32G, 512M, 1T) to bytes.  Sizes
+// without a unit are bytes.
+func ParseSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	switch strings.ToUpper(size[len(size)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	case "T":
+		multiplier = 1 << 40
+	}
+	number := size
+	if multiplier > 1 {
+		number = size[:len(size)-1]
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(value) || value < 0 || value > float64(1<<62)/float64(multiplier) {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// VMDisks returns the disks of a VM and the mount points of a container,
+// leaving out CD-ROM and cloud-init drives
+func (g GuestConfig) VMDisks() []sync.Disk {
+	disks := make([]sync.Disk, 0, len(g.Disks)+len(g.Mounts))
+	for _, disk := range g.Disks {
+		if disk.IsCDROM() || disk.IsCloudInit() {
+			continue
+		}
+		disks = append(disks, sync.Disk{ID: disk.Key, Name: disk.Key, Size: disk.Size, Description: disk.File})
+	}
+	for _, mount := range g.Mounts {
+		disks = append(disks, sync.Disk{ID: mount.Key, Name: mount.Path, Size: mount.Size, Description: mount.Volume})
+	}
+	return disks
+}
+
+// DiskSize returns the total size in bytes of the disks returned by VMDisks
+func (g GuestConfig) DiskSize() int64 {
+	var size int64
+	for _, disk := range g.VMDisks() {
+		size += disk.Size
+	}
+	return size
+}
@@ -0,0 +1,328 @@
+package proxmoxcfg
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// qemuNICModels are the QEMU network card models, which are given as
+// model=macaddr in the netN config of a VM
+var qemuNICModels = map[string]bool{
+	"virtio": true, "e1000": true, "e1000e": true, "e1000-82540em": true, "e1000-82544gc": true,
+	"e1000-82545em": true, "i82551": true, "i82557b": true, "i82559er": true, "ne2k_isa": true,
+	"ne2k_pci": true, "pcnet": true, "rtl8139": true, "vmxnet3": true,
+}
+
+// NetDevice is a netN entry of a VM or container
+type NetDevice struct {
+	Key   string
+	Index int
+	// Model is the NIC model of a VM or the interface type of a container (veth)
+	Model  string
+	MAC    string
+	Bridge string
+	// Tag is the VLAN tag, 0 if untagged
+	Tag      int
+	Trunks   string
+	Firewall bool
+	LinkDown bool
+	// Rate is the rate limit in MB/s, 0 if unlimited
+	Rate   float64
+	MTU    int
+	Queues int
+	// Name, IP, IP6, GW and GW6 are only set for containers
+	Name string
+	IP   string
+	IP6  string
+	GW   string
+	GW6  string
+	Raw  string
+}
+
+// ParseNet parses the netN config entry of a VM or container
+func ParseNet(key string, value string) (NetDevice, error) {
+	nic := NetDevice{Key: key, Raw: value}
+	idx, err := strconv.Atoi(strings.TrimPrefix(key, "net"))
+	if err != nil {
+		return nic, fmt.Errorf("invalid net key %q", key)
+	}
+	nic.Index = idx
+	props := ParseProperties(value)
+	for _, k := range props.Order {
+		v := props.Values[k]
+		switch {
+		case qemuNICModels[k]:
+			nic.Model = k
+			nic.MAC = v
+		case k == "model":
+			nic.Model = v
+		case k == "macaddr" || k == "hwaddr":
+			nic.MAC = v
+		case k == "type":
+			nic.Model = v
+		}
+	}
+	if nic.MAC != "" {
+		if _, err := net.ParseMAC(nic.MAC); err != nil {
+			mac := nic.MAC
+			nic.MAC = ""
+			return nic, fmt.Errorf("invalid MAC address %q", mac)
+		}
+		nic.MAC = strings.ToUpper(nic.MAC)
+	}
+	nic.Bridge = props.Get("bridge")
+	nic.Trunks = props.Get("trunks")
+	nic.Firewall = props.Bool("firewall")
+	nic.LinkDown = props.Bool("link_down")
+	nic.Name = props.Get("name")
+	nic.IP = props.Get("ip")
+	nic.IP6 = props.Get("ip6")
+	nic.GW = props.Get("gw")
+	nic.GW6 = props.Get("gw6")
+	var errs []error
+	if nic.Tag, err = props.Int("tag"); err != nil {
+		errs = append(errs, err)
+	}
+	if nic.MTU, err = props.Int("mtu"); err != nil {
+		errs = append(errs, err)
+	}
+	if nic.Queues, err = props.Int("queues"); err != nil {
+		errs = append(errs, err)
+	}
+	if rate := props.Get("rate"); rate != "" {
+		if nic.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			errs = append(errs, fmt.Errorf("invalid rate %q", rate))
+		}
+	}
+	return nic, errors.Join(errs...)
+}
+
+// Addresses returns the static addresses of a container interface in CIDR
+// notation, skipping dhcp, auto and manual
+func (n NetDevice) Addresses() []string {
+	return staticAddresses(n.IP, n.IP6)
+}
+
+// IPConfig is a cloud-init ipconfigN entry of a VM
+type IPConfig struct {
+	IP  string
+	GW  string
+	IP6 string
+	GW6 string
+}
+
+// ParseIPConfig parses the ipconfigN config entry of a VM
+func ParseIPConfig(value string) IPConfig {
+	props := ParseProperties(value)
+	return IPConfig{
+		IP:  props.Get("ip"),
+		GW:  props.Get("gw"),
+		IP6: props.Get("ip6"),
+		GW6: props.Get("gw6"),
+	}
+}
+
+// Addresses returns the static addresses of the cloud-init config in CIDR
+// notation, skipping dhcp, auto and manual
+func (c IPConfig) Addresses() []string {
+	return staticAddresses(c.IP, c.IP6)
+}
+
+func staticAddresses(addresses ...string) []string {
+	ips := make([]string, 0)
+	for _, address := range addresses {
+		if _, _, err := net.ParseCIDR(address); err == nil {
+			ips = append(ips, address)
+		}
+	}
+	return ips
+}
+
+// Disk is a scsiN, virtioN, sataN or ideN entry of a VM
+type Disk struct {
+	Key string
+	// Bus is scsi, virtio, sata or ide
+	Bus   string
+	Index int
+	// File is the volume (storage:volume), an absolute path or none
+	File    string
+	Storage string
+	Volume  string
+	// Size is the size in bytes
+	Size  int64
+	Media string
+	Raw   string
+}
+
+// ParseDisk parses a disk config entry of a VM
+func ParseDisk(key string, value string) (Disk, error) {
+	disk := Disk{Key: key, Raw: value}
+	match := diskKey.FindStringSubmatch(key)
+	if match == nil {
+		return disk, fmt.Errorf("invalid disk key %q", key)
+	}
+	disk.Bus = match[1]
+	disk.Index, _ = strconv.Atoi(match[2])
+	props := ParseProperties(value)
+	disk.File = props.Default
+	if disk.File == "" {
+		disk.File = props.Get("file")
+	}
+	if storage, volume, ok := strings.Cut(disk.File, ":"); ok {
+		disk.Storage = storage
+		disk.Volume = volume
+	}
+	disk.Media = props.Get("media")
+	var err error
+	disk.Size, err = ParseSize(props.Get("size"))
+	return disk, err
+}
+
+// IsCDROM returns true if the disk is a CD-ROM drive
+func (d Disk) IsCDROM() bool {
+	return d.Media == "cdrom"
+}
+
+// IsCloudInit returns true if the disk is the cloud-init drive
+func (d Disk) IsCloudInit() bool {
+	return strings.Contains(d.Volume, "cloudinit")
+}
+
+// Mount is the rootfs or an mpN mount point of a container
+type Mount struct {
+	Key    string
+	Volume string
+	// Path is the mount path in the container, / for the rootfs
+	Path string
+	// Size is the size in bytes
+	Size int64
+	Raw  string
+}
+
+// ParseMount parses the rootfs or an mpN config entry of a container
+func ParseMount(key string, value string) (Mount, error) {
+	mount := Mount{Key: key, Raw: value}
+	props := ParseProperties(value)
+	mount.Volume = props.Default
+	if mount.Volume == "" {
+		mount.Volume = props.Get("volume")
+	}
+	mount.Path = props.Get("mp")
+	if key == "rootfs" {
+		mount.Path = "/"
+	}
+	var err error
+	mount.Size, err = ParseSize(props.Get("size"))
+	return mount, err
+}
+
+// CPU is the CPU config of a VM or container
+type CPU struct {
+	Cores   int
+	Sockets int
+	// VCPUs is the number of hotplugged vCPUs of a VM, 0 if not limited
+	VCPUs int
+}
+
+// ParseCPU reads the cores, sockets and vcpus config entries
+func ParseCPU(cfg Config) (CPU, error) {
+	cpu := CPU{Cores: 1, Sockets: 1}
+	var errs []error
+	for key, target := range map[string]*int{"cores": &cpu.Cores, "sockets": &cpu.Sockets, "vcpus": &cpu.VCPUs} {
+		value, ok := cfg[key]
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s %q", key, value))
+			continue
+		}
+		*target = i
+	}
+	return cpu, errors.Join(errs...)
+}
+
+// Count returns the number of vCPUs available to the guest
+func (c CPU) Count() int {
+	if c.VCPUs > 0 {
+		return c.VCPUs
+	}
+	return c.Cores * c.Sockets
+}
+
+// Memory is the memory config of a VM or container in MiB
+type Memory struct {
+	Memory int
+	// Balloon is the minimum memory of a VM, 0 if ballooning is disabled
+	Balloon int
+}
+
+// ParseMemory reads the memory and balloon config entries.  The memory may be
+// given as a plain number or as current=<number>.
+func ParseMemory(cfg Config) (Memory, error) {
+	mem := Memory{}
+	var errs []error
+	if value, ok := cfg["memory"]; ok {
+		props := ParseProperties(value)
+		current := props.Default
+		if c := props.Get("current"); c != "" {
+			current = c
+		}
+		i, err := strconv.Atoi(current)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid memory %q", value))
+		}
+		mem.Memory = i
+	}
+	if value, ok := cfg["balloon"]; ok {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid balloon %q", value))
+		}
+		mem.Balloon = i
+	}
+	return mem, errors.Join(errs...)
+}
+
+// SMBIOS is the smbios1 config of a VM
+type SMBIOS struct {
+	UUID         string
+	Serial       string
+	Manufacturer string
+	Product      string
+	Version      string
+	SKU          string
+	Family       string
+}
+
+// ParseSMBIOS parses the smbios1 config entry.  Values other than the UUID
+// are decoded when they are base64 encoded (base64=1).
+func ParseSMBIOS(value string) (SMBIOS, error) {
+	props := ParseProperties(value)
+	smbios := SMBIOS{UUID: props.Get("uuid")}
+	var errs []error
+	decode := func(key string) string {
+		v := props.Get(key)
+		if v == "" || !props.Bool("base64") {
+			return v
+		}
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid base64 %s %q", key, v))
+			return v
+		}
+		return string(decoded)
+	}
+	smbios.Serial = decode("serial")
+	smbios.Manufacturer = decode("manufacturer")
+	smbios.Product = decode("product")
+	smbios.Version = decode("version")
+	smbios.SKU = decode("sku")
+	smbios.Family = decode("family")
+	return smbios, errors.Join(errs...)
+}
@@ -0,0 +1,204 @@
+package proxmoxcfg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sizeUnits are the size suffixes and their shift
+var sizeUnits = []struct {
+	suffix string
+	shift  uint
+}{{"", 0}, {"K", 10}, {"M", 20}, {"G", 30}, {"T", 40}}
+
+// token reports whether s can be used as a property value as is: the
+// property parser splits on , and = and trims spaces
+func token(s string) bool {
+	return s != "" && !strings.ContainsAny(s, ",=") && strings.TrimSpace(s) == s
+}
+
+// formatProperties formats the properties back into a property string
+func formatProperties(p Properties) string {
+	entries := make([]string, 0, len(p.Order)+1)
+	if p.Default != "" {
+		entries = append(entries, p.Default)
+	}
+	for _, key := range p.Order {
+		entries = append(entries, key+"="+p.Values[key])
+	}
+	return strings.Join(entries, ",")
+}
+
+func FuzzParseProperties(f *testing.F) {
+	f.Add("local-lvm:vm-100-disk-0,cache=writeback,size=32G")
+	f.Add("virtio=BC:24:11:2A:3B:4C,bridge=vmbr0,tag=10,firewall=1")
+	f.Add("current=2048")
+	f.Add(" a = b ,, =c,d=e=f,a=g,h")
+	f.Fuzz(func(t *testing.T, value string) {
+		props := ParseProperties(value)
+		if len(props.Order) != len(props.Values) {
+			t.Fatalf("%q: %d keys in order, %d values", value, len(props.Order), len(props.Values))
+		}
+		formatted := formatProperties(props)
+		again := ParseProperties(formatted)
+		if again.Default != props.Default || !reflect.DeepEqual(again.Order, props.Order) || !reflect.DeepEqual(again.Values, props.Values) {
+			t.Fatalf("%q: %q parsed as %+v, expected %+v", value, formatted, again, props)
+		}
+	})
+}
+
+func FuzzParseSize(f *testing.F) {
+	f.Add(uint32(32), uint8(3), "32G")
+	f.Add(uint32(512), uint8(2), "0.5T")
+	f.Add(uint32(0), uint8(0), "-1K")
+	f.Add(uint32(4194304), uint8(4), "NaN")
+	f.Fuzz(func(t *testing.T, n uint32, unit uint8, raw string) {
+		if size, err := ParseSize(raw); err == nil && size < 0 {
+			t.Fatalf("%q: negative size %d", raw, size)
+		}
+		u := sizeUnits[int(unit)%len(sizeUnits)]
+		value := fmt.Sprintf("%d%s", n, u.suffix)
+		size, err := ParseSize(value)
+		if uint64(n) > uint64(1<<62)>>u.shift {
+			if err == nil {
+				t.Fatalf("%q: expected an overflow error, got %d", value, size)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if expected := int64(n) << u.shift; size != expected {
+			t.Fatalf("%q: size %d, expected %d", value, size, expected)
+		}
+	})
+}
+
+func FuzzParseNet(f *testing.F) {
+	f.Add(uint8(0), []byte{0xbc, 0x24, 0x11, 0x2a, 0x3b, 0x4c}, "vmbr0", uint16(10), true, uint16(1500), "virtio=BC:24:11:2A:3B:4C,bridge=vmbr0")
+	f.Add(uint8(1), []byte{0, 1, 2, 3, 4, 5}, "vmbr1", uint16(0), false, uint16(0), "name=eth0,hwaddr=zz,ip=dhcp,tag=x")
+	f.Fuzz(func(t *testing.T, model uint8, mac []byte, bridge string, tag uint16, firewall bool, mtu uint16, raw string) {
+		_, _ = ParseNet("net0", raw)
+		if len(mac) < 6 || !token(bridge) {
+			t.Skip()
+		}
+		models := []string{"virtio", "e1000", "vmxnet3", "rtl8139"}
+		nicModel := models[int(model)%len(models)]
+		hwaddr := net.HardwareAddr(mac[:6]).String()
+		value := fmt.Sprintf("%s=%s,bridge=%s,tag=%d,firewall=%s,mtu=%d", nicModel, hwaddr, bridge, tag, boolValue(firewall), mtu)
+		nic, err := ParseNet("net3", value)
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		expected := NetDevice{
+			Key: "net3", Index: 3, Model: nicModel, MAC: strings.ToUpper(hwaddr), Bridge: bridge,
+			Tag: int(tag), Firewall: firewall, MTU: int(mtu), Raw: value,
+		}
+		if !reflect.DeepEqual(nic, expected) {
+			t.Fatalf("%q: parsed as %+v, expected %+v", value, nic, expected)
+		}
+	})
+}
+
+func FuzzParseIPConfig(f *testing.F) {
+	f.Add([]byte{10, 0, 0, 5}, uint8(24), []byte{10, 0, 0, 1}, []byte(net.ParseIP("2001:db8::5")), "ip=dhcp,ip6=auto")
+	f.Fuzz(func(t *testing.T, ip []byte, prefix uint8, gw []byte, ip6 []byte, raw string) {
+		for _, address := range ParseIPConfig(raw).Addresses() {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				t.Fatalf("%q: invalid address %q returned", raw, address)
+			}
+		}
+		if len(ip) < 4 || len(gw) < 4 || len(ip6) < 16 {
+			t.Skip()
+		}
+		address := fmt.Sprintf("%s/%d", net.IP(ip[:4]), prefix%33)
+		address6 := fmt.Sprintf("%s/%d", net.IP(ip6[:16]), prefix%129)
+		value := fmt.Sprintf("ip=%s,gw=%s,ip6=%s", address, net.IP(gw[:4]), address6)
+		cfg := ParseIPConfig(value)
+		expected := IPConfig{IP: address, GW: net.IP(gw[:4]).String(), IP6: address6}
+		if cfg != expected {
+			t.Fatalf("%q: parsed as %+v, expected %+v", value, cfg, expected)
+		}
+		if addresses := cfg.Addresses(); !reflect.DeepEqual(addresses, []string{address, address6}) {
+			t.Fatalf("%q: addresses %v", value, addresses)
+		}
+	})
+}
+
+func FuzzParseDisk(f *testing.F) {
+	f.Add(uint8(0), uint8(0), "local-lvm", "vm-100-disk-0", uint32(32), uint8(3), false, "local:iso/debian.iso,media=cdrom")
+	f.Add(uint8(2), uint8(2), "ceph", "vm-100-cloudinit", uint32(4), uint8(2), true, "none,size=abc")
+	f.Fuzz(func(t *testing.T, bus uint8, index uint8, storage string, volume string, n uint32, unit uint8, cdrom bool, raw string) {
+		_, _ = ParseDisk("scsi0", raw)
+		if !token(storage) || strings.Contains(storage, ":") || !token(volume) {
+			t.Skip()
+		}
+		buses := []string{"scsi", "virtio", "sata", "ide"}
+		key := fmt.Sprintf("%s%d", buses[int(bus)%len(buses)], index)
+		u := sizeUnits[int(unit)%len(sizeUnits)]
+		n >>= 10 // stay clear of the size limit
+		value := fmt.Sprintf("%s:%s,size=%d%s", storage, volume, n, u.suffix)
+		expected := Disk{
+			Key: key, Bus: buses[int(bus)%len(buses)], Index: int(index), File: storage + ":" + volume,
+			Storage: storage, Volume: volume, Size: int64(n) << u.shift, Raw: value,
+		}
+		if cdrom {
+			value += ",media=cdrom"
+			expected.Media = "cdrom"
+			expected.Raw = value
+		}
+		disk, err := ParseDisk(key, value)
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if !reflect.DeepEqual(disk, expected) {
+			t.Fatalf("%q: parsed as %+v, expected %+v", value, disk, expected)
+		}
+		if disk.IsCDROM() != cdrom {
+			t.Fatalf("%q: IsCDROM %v", value, disk.IsCDROM())
+		}
+	})
+}
+
+func FuzzParseSMBIOS(f *testing.F) {
+	f.Add("4c4c4544-0038-3010-8056-b4c04f4e3032", "CZ1234", "Dell Inc.", true, "uuid=x,base64=1,serial=!!!")
+	f.Add("0b6a0d1e-8f3c-4a4f-9d8a-3c2e1f0a9b7c", "abc", "QEMU", false, "")
+	f.Fuzz(func(t *testing.T, uuid string, serial string, manufacturer string, encoded bool, raw string) {
+		_, _ = ParseSMBIOS(raw)
+		if !token(uuid) {
+			t.Skip()
+		}
+		encode := func(v string) string {
+			if encoded {
+				return base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			return v
+		}
+		if serial == "" || manufacturer == "" || (!encoded && (!token(serial) || !token(manufacturer))) {
+			t.Skip()
+		}
+		value := fmt.Sprintf("uuid=%s,serial=%s,manufacturer=%s", uuid, encode(serial), encode(manufacturer))
+		if encoded {
+			value += ",base64=1"
+		}
+		smbios, err := ParseSMBIOS(value)
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		expected := SMBIOS{UUID: uuid, Serial: serial, Manufacturer: manufacturer}
+		if smbios != expected {
+			t.Fatalf("%q: parsed as %+v, expected %+v", value, smbios, expected)
+		}
+	})
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
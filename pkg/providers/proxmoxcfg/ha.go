@@ -0,0 +1,60 @@
+package proxmoxcfg
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// HAResource is an entry of the cluster/ha/resources API
+type HAResource struct {
+	// SID is the HA service ID, eg. vm:100 or ct:101
+	SID   string `json:"sid"`
+	Group string `json:"group"`
+	State string `json:"state"`
+}
+
+// VMID returns the guest ID of the HA resource, or 0 if the SID is invalid
+func (h HAResource) VMID() int {
+	_, id, ok := strings.Cut(h.SID, ":")
+	if !ok {
+		return 0
+	}
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return 0
+	}
+	return vmid
+}
+
+// HAResourcesByVMID returns the HA resources indexed by guest ID
+func HAResourcesByVMID(resources []HAResource) map[int]HAResource {
+	byID := make(map[int]HAResource, len(resources))
+	for _, resource := range resources {
+		if vmid := resource.VMID(); vmid > 0 {
+			byID[vmid] = resource
+		}
+	}
+	return byID
+}
+
+// Metadata returns the pool and HA metadata of a guest.  The HA state is
+// taken from the HA resource, falling back to the state of the cluster
+// resource.
+func Metadata(pool string, haState string, ha HAResource) map[string]string {
+	metadata := make(map[string]string)
+	if pool != "" {
+		metadata[sync.MetaPool] = pool
+	}
+	if ha.Group != "" {
+		metadata[sync.MetaHAGroup] = ha.Group
+	}
+	if ha.State != "" {
+		haState = ha.State
+	}
+	if haState != "" {
+		metadata[sync.MetaHAState] = haState
+	}
+	return metadata
+}
@@ -0,0 +1,68 @@
+package proxmoxdc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ringsq/netboxvmsync/pkg/providers/proxmoxcfg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// lxcType is the resource type of LXC containers
+const lxcType = "lxc"
+
+// loadContainerConfig reads the container config of the remote and fills in
+// the description, cores, memory, mount point sizes and interfaces of the VM
+func (p *PDMProvider) loadContainerConfig(ctx context.Context, remote string, vm *sync.VM) {
+	cfg := make(map[string]interface{})
+	if err := p.client.Get(ctx, fmt.Sprintf("/pve/remotes/%s/lxc/%s/config", remote, vm.ID), &cfg); err != nil {
+		p.log.Warn("could not retrieve container config", "vm", vm.Name, "error", err)
+		return
+	}
+	guest, err := proxmoxcfg.Parse(proxmoxcfg.New(cfg))
+	if err != nil {
+		p.log.Warn("could not parse all of the container config", "vm", vm.Name, "error", err)
+	}
+	vm.Description = guest.Description
+	if guest.Memory.Memory > 0 {
+		vm.Memory = guest.Memory.Memory
+	}
+	if _, ok := cfg["cores"]; ok {
+		vm.VCPUs = float32(guest.CPU.Cores)
+	}
+	vm.Disks = guest.VMDisks()
+	if size := guest.DiskSize(); size > 0 {
+		vm.Diskspace = int(size / gb)
+	}
+	for _, net := range guest.Nets {
+		nic := sync.NIC{ID: net.Key, Name: net.Key, MAC: net.MAC, Description: net.Raw}
+		if net.Name != "" {
+			nic.Name = net.Name
+		}
+		nic.IP = make([]string, 0)
+		for _, ip := range net.Addresses() {
+			nic.AddIP(ip, sync.IPSourceConfig)
+		}
+		vm.Network = append(vm.Network, nic)
+	}
+}
+
+// loadContainerAddresses adds the addresses reported by the running
+// container to the interfaces of the VM.  The interfaces are read through
+// the PDM proxy API, which fails if the remote does not support it.
+func (p *PDMProvider) loadContainerAddresses(ctx context.Context, remote string, vm *sync.VM) {
+	interfaces := make([]proxmoxcfg.ContainerInterface, 0)
+	path := fmt.Sprintf("/pve/remotes/%s/lxc/%s/interfaces", remote, vm.ID)
+	if err := p.client.Get(ctx, path, &interfaces); err != nil {
+		p.log.Debug("could not retrieve container interfaces", "vm", vm.Name, "error", err)
+		return
+	}
+	for i := range vm.Network {
+		nic := &vm.Network[i]
+		if intf, found := proxmoxcfg.FindContainerInterface(interfaces, nic.MAC); found {
+			for _, ip := range intf.Addresses() {
+				nic.AddIP(ip, sync.IPSourceAgent)
+			}
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/providers/proxmoxcfg"
 	"github.com/ringsq/netboxvmsync/pkg/sync"
 	pdm "github.com/srerun/go-proxmox-pdm"
 )
@@ -21,15 +22,18 @@ const gb = 1073741824
 const mb = 1048576
 
 type PDMProvider struct {
-	client    *pdm.Client
-	log       pkg.Logger
-	resources pdm.Resources
+	client         *pdm.Client
+	log            pkg.Logger
+	resources      pdm.Resources
+	remotes        []remote
+	datacenterMode string
+	remoteGroups   map[string]string
 }
 
 var ErrNotImplemented = errors.New("not implemented")
 
 // NewVmwareProvider creates a new VM sync provider using vmware vcenter
-func NewProxmoxDCProvider(baseURL string, username string, password string, logger pkg.Logger) (*PDMProvider, error) {
+func NewProxmoxDCProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*PDMProvider, error) {
 	insecureHTTPClient := http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{
@@ -37,10 +41,16 @@ func NewProxmoxDCProvider(baseURL string, username string, password string, logg
 			},
 		},
 	}
-	pdmprov := &PDMProvider{log: logger}
+	pdmprov := &PDMProvider{log: logger, datacenterMode: DatacenterSingle}
 	if log, ok := logger.(*slog.Logger); ok {
 		pdmprov.log = log.With("provider", pdmprov.GetName())
 	}
+	for _, opt := range opts {
+		opt(pdmprov)
+	}
+	if err := pdmprov.validate(); err != nil {
+		return pdmprov, err
+	}
 	pdmprov.log.Info("Connecting...", "user", username)
 	pdmprov.client = pdm.NewClient(fmt.Sprintf("%s/api2/json", baseURL),
 		pdm.WithHTTPClient(&insecureHTTPClient),
@@ -51,6 +61,9 @@ func NewProxmoxDCProvider(baseURL string, username string, password string, logg
 		return pdmprov, err
 	}
 	pdmprov.log.Info("connected to proxmox datacenter manager", "version", version)
+	if err = pdmprov.loadRemotes(context.Background()); err != nil {
+		return pdmprov, err
+	}
 	return pdmprov, nil
 }
 
@@ -58,36 +71,48 @@ func (p *PDMProvider) GetName() string {
 	return "Proxmox"
 }
 
-// GetDatacenters returns a list of all datacenters managed by this provider
+// GetDatacenters returns a list of all datacenters managed by this provider.
+// Each datacenter is the cluster group of one or more PVE remotes.
 func (p *PDMProvider) GetDatacenters() ([]sync.Datacenter, error) {
-	var err error
-	dc := sync.Datacenter{}
-	dc.Name = p.GetName()
-	dc.ID = p.GetName()
-	dc.Description = "Proxmox Clusters"
-	p.resources, err = p.client.Resources(context.Background())
-	return []sync.Datacenter{dc}, err
+	dcs := make([]sync.Datacenter, 0)
+	seen := make(map[string]bool)
+	for _, r := range p.remotes {
+		group := p.remoteGroup(r)
+		if seen[group] {
+			continue
+		}
+		seen[group] = true
+		dcs = append(dcs, sync.Datacenter{ID: group, Name: group, Description: "Proxmox Clusters"})
+	}
+	return dcs, nil
 }
 
 // GetDcClusters gets a list of clusters for the given datacenter ID
 func (p *PDMProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
 	clusters := make([]sync.Cluster, 0)
-	for _, cluster := range p.resources {
-		if cluster.Remote == "Backup" { // skip backup server entry
+	for _, r := range p.remotes {
+		if p.remoteGroup(r) != datacenterID {
 			continue
 		}
-		clusters = append(clusters, sync.Cluster{Name: cluster.Remote, ID: cluster.Remote})
+		clusters = append(clusters, sync.Cluster{Name: r.ID, ID: r.ID})
 	}
 	return clusters, nil
 }
 
 // GetClusterVMs returns a list of VMs for the given cluster ID
 func (p *PDMProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	if !p.isPVERemote(clusterID) {
+		return nil, fmt.Errorf("%s is not a PVE remote", clusterID)
+	}
+	if err := p.loadResources(context.Background()); err != nil {
+		return nil, err
+	}
 	vms := make([]sync.VM, 0)
 	for _, cluster := range p.resources {
 		if cluster.Remote != clusterID {
 			continue
 		}
+		haResources := p.haResources(context.Background(), clusterID)
 		clustervms := pdm.FilterClusterResourcesByType(cluster.Resources, pdm.VMType)
 		for _, resource := range clustervms {
 			if resource.Template { // skip templates
@@ -97,10 +122,12 @@ func (p *PDMProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 			id := strings.Split(resource.ID, "/")
 			vm.ID = fmt.Sprint(id[len(id)-1])
 			vm.Name = resource.Name
-			vm.Description = resource.Type
+			vm.Type = sync.VMTypeVirtualMachine
+			vm.Metadata = proxmoxcfg.Metadata(resource.Pool, "", haResources[guestID(vm.ID)])
 			vm.Memory = int(resource.MaxMem / mb)
 			vm.Diskspace = int(resource.MaxDisk / gb)
 			vm.VCPUs = float32(resource.MaxCPU)
+			vm.PowerState = resource.Status
 			if resource.Status == "running" {
 				vm.Status = "active"
 			} else {
@@ -113,57 +140,105 @@ func (p *PDMProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 			if err == nil {
 				cfg, err := p.client.GetVMConfig(context.Background(), clusterID, vmid)
 				if err == nil {
-					for key, value := range cfg {
-						if key == "description" {
-							vm.Description = fmt.Sprint(value)
-						}
-						if strings.HasPrefix(key, "net") {
-							nicData := splitFieldValue(fmt.Sprint(value))
-							nic := sync.NIC{}
-							nic.MAC = nicData["mac"]
-							nic.Description = nicData["description"]
-							nic.ID = key
-							nic.Name = key
-							netdev := strings.Split(key, "net")
-							if len(netdev) == 2 {
-								nicID := netdev[1]
-								ipconfig := fmt.Sprintf("ipconfig%s", nicID)
-								if ipdata, ok := cfg[ipconfig]; ok {
-									ipfields := splitFieldValue(fmt.Sprint(ipdata))
-									if ip, ok := ipfields["ip"]; ok {
-										nic.IP = []string{ip}
-									}
-								}
-							}
-							vm.Network = append(vm.Network, nic)
-						}
-					}
+					p.loadVMConfig(cfg, &vm)
+				}
+				if resource.Status == "running" {
+					p.loadAgentAddresses(context.Background(), clusterID, vmid, &vm)
 				}
 			}
 
 			vms = append(vms, vm)
 		}
+		containers := pdm.FilterClusterResourcesByType(cluster.Resources, lxcType)
+		for _, resource := range containers {
+			if resource.Template { // skip templates
+				continue
+			}
+			vm := sync.VM{}
+			id := strings.Split(resource.ID, "/")
+			vm.ID = fmt.Sprint(id[len(id)-1])
+			vm.Name = resource.Name
+			vm.Type = sync.VMTypeContainer
+			vm.Metadata = proxmoxcfg.Metadata(resource.Pool, "", haResources[guestID(vm.ID)])
+			vm.Memory = int(resource.MaxMem / mb)
+			vm.Diskspace = int(resource.MaxDisk / gb)
+			vm.VCPUs = float32(resource.MaxCPU)
+			vm.PowerState = resource.Status
+			if resource.Status == "running" {
+				vm.Status = "active"
+			} else {
+				vm.Status = "offline"
+			}
+			vm.Network = make([]sync.NIC, 0)
+			p.loadContainerConfig(context.Background(), clusterID, &vm)
+			if resource.Status == "running" {
+				p.loadContainerAddresses(context.Background(), clusterID, &vm)
+			}
+			vms = append(vms, vm)
+		}
 	}
 	return vms, nil
 }
 
-// splitFieldValues takes the field of the form key=value,key=value
-// and returns it as a map of [key]value
-func splitFieldValue(field string) map[string]string {
-	data := make(map[string]string)
-	fields := strings.Split(field, ",")
-	idx := 0
-	for _, f := range fields {
-		values := strings.Split(f, "=")
-		data[values[0]] = values[1]
-		if len(values) == 2 {
-			if idx == 0 {
-				data["description"] = field
-				data["mac"] = values[1]
-				data["driver"] = values[0]
+// haResources returns the HA resources of the remote indexed by guest ID.
+// The resources are read through the PDM proxy API, which fails if the
+// remote does not support it.
+func (p *PDMProvider) haResources(ctx context.Context, remote string) map[int]proxmoxcfg.HAResource {
+	resources := make([]proxmoxcfg.HAResource, 0)
+	if err := p.client.Get(ctx, fmt.Sprintf("/pve/remotes/%s/cluster/ha/resources", remote), &resources); err != nil {
+		p.log.Debug("could not retrieve HA resources", "remote", remote, "error", err)
+	}
+	return proxmoxcfg.HAResourcesByVMID(resources)
+}
+
+// guestID converts the guest ID to an int, returning 0 if it is invalid
+func guestID(id string) int {
+	vmid, _ := strconv.Atoi(id)
+	return vmid
+}
+
+// loadVMConfig fills in the description, serial and interfaces of the VM
+// from its config
+func (p *PDMProvider) loadVMConfig(cfg map[string]interface{}, vm *sync.VM) {
+	guest, err := proxmoxcfg.Parse(proxmoxcfg.New(cfg))
+	if err != nil {
+		p.log.Warn("could not parse all of the VM config", "vm", vm.Name, "error", err)
+	}
+	vm.Description = guest.Description
+	vm.Serial = guest.SMBIOS.UUID
+	vm.Disks = guest.VMDisks()
+	if size := guest.DiskSize(); size > 0 {
+		vm.Diskspace = int(size / gb)
+	}
+	for _, net := range guest.Nets {
+		nic := sync.NIC{ID: net.Key, Name: net.Key, MAC: net.MAC, Description: net.Raw}
+		nic.IP = make([]string, 0)
+		if ipconfig, ok := guest.IPConfigs[net.Index]; ok {
+			for _, ip := range ipconfig.Addresses() {
+				nic.AddIP(ip, sync.IPSourceCloudInit)
+			}
+		}
+		vm.Network = append(vm.Network, nic)
+	}
+}
+
+// loadAgentAddresses adds the addresses reported by the QEMU guest agent to
+// the interfaces of the VM.  The agent is queried through the PDM proxy API,
+// which fails if the remote or the guest does not support it.
+func (p *PDMProvider) loadAgentAddresses(ctx context.Context, remote string, vmid int, vm *sync.VM) {
+	agent := proxmoxcfg.AgentResult{}
+	path := fmt.Sprintf("/pve/remotes/%s/qemu/%d/agent/network-get-interfaces", remote, vmid)
+	if err := p.client.Get(ctx, path, &agent); err != nil {
+		p.log.Debug("could not retrieve guest agent interfaces", "vm", vm.Name, "error", err)
+		return
+	}
+	for i := range vm.Network {
+		nic := &vm.Network[i]
+		if agentIF, found := proxmoxcfg.FindAgentInterface(agent.Result, nic.MAC); found {
+			nic.Name = agentIF.Name
+			for _, ip := range agentIF.Addresses() {
+				nic.AddIP(ip, sync.IPSourceAgent)
 			}
 		}
-		idx++
 	}
-	return data
 }
@@ -0,0 +1,27 @@
+package proxmoxdc
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "proxmoxdc",
+		Description: "Proxmox Datacenter Manager, syncing the VMs of its PVE remotes",
+		Settings: []providers.Setting{
+			{Name: "PDM_DATACENTER_MODE", Default: DatacenterSingle, Description: "how remotes are mapped to Netbox cluster groups, single, remote or domain"},
+			{Name: "PDM_REMOTE_GROUPS", Description: "the cluster group of individual remotes, eg. remote1:East,remote2:West"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			remoteGroups, err := ParseRemoteGroups(cfg.Get("PDM_REMOTE_GROUPS"))
+			if err != nil {
+				return nil, err
+			}
+			return NewProxmoxDCProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithDatacenterMode(cfg.Get("PDM_DATACENTER_MODE")),
+				WithRemoteGroups(remoteGroups),
+			)
+		},
+	})
+}
@@ -0,0 +1,163 @@
+package proxmoxdc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Remote types reported by the PDM remotes API
+const (
+	RemoteTypePVE = "pve"
+	RemoteTypePBS = "pbs"
+)
+
+// Datacenter modes decide how remotes are mapped to Netbox cluster groups
+const (
+	// DatacenterSingle puts every remote in one cluster group named Proxmox
+	DatacenterSingle = "single"
+	// DatacenterRemote gives every remote its own cluster group
+	DatacenterRemote = "remote"
+	// DatacenterDomain groups the remotes by the DNS domain of their nodes,
+	// eg. pve1.east.example.com is in the east.example.com group
+	DatacenterDomain = "domain"
+)
+
+// remote is an entry of the PDM remotes API
+type remote struct {
+	ID    string       `json:"id"`
+	Type  string       `json:"type"`
+	Nodes []remoteNode `json:"nodes"`
+}
+
+// remoteNode is a node PDM connects to for a remote
+type remoteNode struct {
+	// Hostname is the host name or address of the node, optionally with a port
+	Hostname string `json:"hostname"`
+}
+
+// domain returns the DNS domain of the first node of the remote with a
+// domain, or an empty string if none has one
+func (r remote) domain() string {
+	for _, node := range r.Nodes {
+		host := node.Hostname
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		if _, domain, ok := strings.Cut(host, "."); ok && domain != "" {
+			return domain
+		}
+	}
+	return ""
+}
+
+// Option configures the PDM provider
+type Option func(*PDMProvider)
+
+// WithDatacenterMode sets how remotes are mapped to Netbox cluster groups
+func WithDatacenterMode(mode string) Option {
+	return func(p *PDMProvider) {
+		if mode != "" {
+			p.datacenterMode = strings.ToLower(mode)
+		}
+	}
+}
+
+// WithRemoteGroups sets the cluster group of individual remotes, overriding
+// the datacenter mode for them.  See ParseRemoteGroups.
+func WithRemoteGroups(groups map[string]string) Option {
+	return func(p *PDMProvider) {
+		p.remoteGroups = groups
+	}
+}
+
+// ParseRemoteGroups converts a comma separated list of remote:group pairs
+// into a map of remote ID to cluster group name
+func ParseRemoteGroups(groups string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(groups, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		remote, group, ok := strings.Cut(pair, ":")
+		remote = strings.TrimSpace(remote)
+		group = strings.TrimSpace(group)
+		if !ok || remote == "" || group == "" {
+			return nil, fmt.Errorf("invalid remote group %q, expected remote:group", pair)
+		}
+		parsed[remote] = group
+	}
+	return parsed, nil
+}
+
+// validate checks the options after they are applied
+func (p *PDMProvider) validate() error {
+	switch p.datacenterMode {
+	case DatacenterSingle, DatacenterRemote, DatacenterDomain:
+	default:
+		return fmt.Errorf("invalid datacenter mode %q, expected %s, %s or %s", p.datacenterMode, DatacenterSingle, DatacenterRemote, DatacenterDomain)
+	}
+	return nil
+}
+
+// loadRemotes reads the remotes from PDM, keeping the PVE remotes
+func (p *PDMProvider) loadRemotes(ctx context.Context) error {
+	remotes := make([]remote, 0)
+	if err := p.client.Get(ctx, "/remotes", &remotes); err != nil {
+		return fmt.Errorf("could not retrieve remotes: %w", err)
+	}
+	p.remotes = make([]remote, 0, len(remotes))
+	for _, r := range remotes {
+		if !strings.EqualFold(r.Type, RemoteTypePVE) {
+			p.log.Debug("skipping remote", "remote", r.ID, "type", r.Type)
+			continue
+		}
+		p.remotes = append(p.remotes, r)
+	}
+	return nil
+}
+
+// loadResources reads the resources of every remote from PDM unless they
+// were already loaded
+func (p *PDMProvider) loadResources(ctx context.Context) error {
+	if p.resources != nil {
+		return nil
+	}
+	resources, err := p.client.Resources(ctx)
+	if err != nil {
+		return err
+	}
+	p.resources = resources
+	return nil
+}
+
+// remoteGroup returns the name of the cluster group of the remote
+func (p *PDMProvider) remoteGroup(r remote) string {
+	if group, ok := p.remoteGroups[r.ID]; ok {
+		return group
+	}
+	switch p.datacenterMode {
+	case DatacenterRemote:
+		return r.ID
+	case DatacenterDomain:
+		if domain := r.domain(); domain != "" {
+			return domain
+		}
+	}
+	return p.GetName()
+}
+
+// isPVERemote returns true if the remote is a known PVE remote
+func (p *PDMProvider) isPVERemote(id string) bool {
+	for _, r := range p.remotes {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
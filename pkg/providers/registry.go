@@ -0,0 +1,189 @@
+// Package providers is the registry of the VM sync providers.  Every
+// provider package registers itself in its init function, so a provider is
+// compiled in by importing its package, eg. for its side effects only:
+//
+//	import _ "github.com/ringsq/netboxvmsync/pkg/providers/libvirt"
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	gosync "sync"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+// ErrUnknownProvider is returned by New for names that are not registered
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// Setting is an environment variable configuring a provider
+type Setting struct {
+	Name        string
+	Description string
+	// Default is used when the variable is not set
+	Default string
+}
+
+// Config is passed to the factory of the provider.  URL, User and Token
+// are the common PROVIDER_URL, PROVIDER_USER and PROVIDER_TOKEN; the
+// settings of the provider are read with Get, Int and Bool.
+type Config struct {
+	URL    string
+	User   string
+	Token  string
+	Logger pkg.Logger
+	Getenv func(string) string
+
+	settings []Setting
+}
+
+// Get returns the value of the setting, or its default if it is not set
+func (c Config) Get(name string) string {
+	if c.Getenv != nil {
+		if value := c.Getenv(name); value != "" {
+			return value
+		}
+	}
+	for _, setting := range c.settings {
+		if setting.Name == name {
+			return setting.Default
+		}
+	}
+	return ""
+}
+
+// Int returns the integer value of the setting, 0 if it has no value
+func (c Config) Int(name string) (int, error) {
+	value := c.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return i, nil
+}
+
+// Bool returns the boolean value of the setting, false if it has no value
+func (c Config) Bool(name string) (bool, error) {
+	value := c.Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return b, nil
+}
+
+// Factory creates a provider from its configuration
+type Factory func(cfg Config) (sync.VMProvider, error)
+
+// Registration describes a provider
+type Registration struct {
+	// Name is the value of PROVIDER selecting the provider, in lower case
+	Name        string
+	Description string
+	// Settings are the environment variables of the provider besides
+	// PROVIDER_URL, PROVIDER_USER and PROVIDER_TOKEN
+	Settings []Setting
+	New      Factory
+}
+
+var (
+	mu       gosync.RWMutex
+	registry = make(map[string]Registration)
+)
+
+// Register makes a provider available by its name.  It panics if the name
+// is empty or already registered, or if the factory is nil.
+func Register(r Registration) {
+	mu.Lock()
+	defer mu.Unlock()
+	r.Name = strings.ToLower(r.Name)
+	if r.Name == "" {
+		panic("providers: Register with an empty name")
+	}
+	if r.New == nil {
+		panic("providers: Register of " + r.Name + " without a factory")
+	}
+	if _, ok := registry[r.Name]; ok {
+		panic("providers: Register called twice for " + r.Name)
+	}
+	registry[r.Name] = r
+}
+
+// Registered returns the registered providers sorted by name
+func Registered() []Registration {
+	mu.RLock()
+	defer mu.RUnlock()
+	registrations := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		registrations = append(registrations, r)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Name < registrations[j].Name
+	})
+	return registrations
+}
+
+// Names returns the names of the registered providers, sorted
+func Names() []string {
+	names := make([]string, 0)
+	for _, r := range Registered() {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// Lookup returns the registration of the provider, ignoring case.  The
+// error of unknown names lists the valid ones.
+func Lookup(name string) (Registration, error) {
+	mu.RLock()
+	r, ok := registry[strings.ToLower(name)]
+	mu.RUnlock()
+	if !ok {
+		return r, fmt.Errorf("%w %q, expected one of: %s", ErrUnknownProvider, name, strings.Join(Names(), ", "))
+	}
+	return r, nil
+}
+
+// New creates the named provider
+func New(name string, cfg Config) (sync.VMProvider, error) {
+	r, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg.settings = r.Settings
+	provider, err := r.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.Name, err)
+	}
+	return provider, nil
+}
+
+// Usage describes the registered providers and their settings
+func Usage() string {
+	var b strings.Builder
+	b.WriteString("Providers:\n")
+	for _, r := range Registered() {
+		fmt.Fprintf(&b, "  %s\n", r.Name)
+		if r.Description != "" {
+			fmt.Fprintf(&b, "      %s\n", r.Description)
+		}
+		for _, setting := range r.Settings {
+			fmt.Fprintf(&b, "      %s", setting.Name)
+			if setting.Default != "" {
+				fmt.Fprintf(&b, " (default %s)", setting.Default)
+			}
+			fmt.Fprintf(&b, ": %s\n", setting.Description)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package vmware
+
+import (
+	"fmt"
+	gosync "sync"
+	"time"
+
+	"github.com/ringsq/vcenterapi/pkg/vcenter"
+)
+
+// Defaults for retrieving the VM details
+const (
+	defaultConcurrency = 8
+	defaultRetries     = 3
+	retryDelay         = time.Second
+)
+
+// Option configures the VMware provider
+type Option func(*VmwareProvider)
+
+// WithConcurrency sets the number of VM details retrieved in parallel
+func WithConcurrency(concurrency int) Option {
+	return func(v *VmwareProvider) {
+		if concurrency > 0 {
+			v.concurrency = concurrency
+		}
+	}
+}
+
+// WithRetries sets the number of times retrieving the details of a VM is
+// retried before the VM is skipped
+func WithRetries(retries int) Option {
+	return func(v *VmwareProvider) {
+		if retries >= 0 {
+			v.retries = retries
+		}
+	}
+}
+
+// vmDetailResult is the outcome of retrieving the details of one VM
+type vmDetailResult struct {
+	detail vcenter.VMDetail
+	err    error
+}
+
+// getVMDetails retrieves the details of the VMs using up to concurrency
+// parallel requests.  The results are keyed by VM ID.
+func (v *VmwareProvider) getVMDetails(vmIDs []string) map[string]vmDetailResult {
+	start := time.Now()
+	results := make(map[string]vmDetailResult, len(vmIDs))
+	var mu gosync.Mutex
+	var wg gosync.WaitGroup
+	ids := make(chan string)
+	for i := 0; i < v.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				detail, err := v.getVMDetail(id)
+				mu.Lock()
+				results[id] = vmDetailResult{detail: detail, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range vmIDs {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+	v.log.Info("retrieved VM details", "vms", len(vmIDs), "concurrency", v.concurrency, "duration", time.Since(start))
+	return results
+}
+
+// getVMDetail retrieves the details of the VM, retrying with an increasing
+// delay when it fails
+func (v *VmwareProvider) getVMDetail(vmID string) (vcenter.VMDetail, error) {
+	var err error
+	for attempt := 0; attempt <= v.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * retryDelay)
+		}
+		var detail vcenter.VMDetail
+		if detail, err = v.vcenter.GetVM(vmID); err == nil {
+			return detail, nil
+		}
+		v.log.Debug("failed to get VM details", "vm", vmID, "attempt", attempt+1, "error", err)
+	}
+	return vcenter.VMDetail{}, fmt.Errorf("could not get details of VM %s after %d attempts: %w", vmID, v.retries+1, err)
+}
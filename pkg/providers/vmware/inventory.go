@@ -0,0 +1,116 @@
+package vmware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// vmInventoryProps are the VM properties needed for the folder, resource
+// pool and vApp of a VM
+var vmInventoryProps = []string{"parent", "parentVApp", "resourcePool", "config.template"}
+
+// inventory resolves the names and parents of inventory objects, caching
+// them for the rest of the sync
+type inventory struct {
+	entities map[types.ManagedObjectReference]mo.ManagedEntity
+}
+
+func newInventory() *inventory {
+	return &inventory{entities: make(map[types.ManagedObjectReference]mo.ManagedEntity)}
+}
+
+// loadEntities retrieves the name and parent of the objects that are not cached
+func (v *VmwareProvider) loadEntities(ctx context.Context, refs []types.ManagedObjectReference) error {
+	missing := make([]types.ManagedObjectReference, 0, len(refs))
+	for _, ref := range refs {
+		if _, ok := v.inventory.entities[ref]; !ok {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) == 0 || v.soap == nil {
+		return nil
+	}
+	var entities []mo.ManagedEntity
+	pc := property.DefaultCollector(v.soap.Client)
+	if err := pc.Retrieve(ctx, missing, []string{"name", "parent"}, &entities); err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		v.inventory.entities[entity.Self] = entity
+	}
+	return nil
+}
+
+// folderPath returns the VM folder path of the inventory object, eg.
+// /Prod/Web.  The path stops at the datacenter and leaves out its root vm
+// folder.
+func (v *VmwareProvider) folderPath(ctx context.Context, ref *types.ManagedObjectReference) (string, error) {
+	names := make([]string, 0)
+	for ref != nil && ref.Type == "Folder" {
+		if err := v.loadEntities(ctx, []types.ManagedObjectReference{*ref}); err != nil {
+			return "", err
+		}
+		entity, ok := v.inventory.entities[*ref]
+		if !ok {
+			break
+		}
+		if entity.Parent != nil && entity.Parent.Type == "Datacenter" {
+			break // the root vm folder
+		}
+		names = append([]string{entity.Name}, names...)
+		ref = entity.Parent
+	}
+	return "/" + strings.Join(names, "/"), nil
+}
+
+// entityName returns the name of the inventory object
+func (v *VmwareProvider) entityName(ctx context.Context, ref *types.ManagedObjectReference) (string, error) {
+	if ref == nil {
+		return "", nil
+	}
+	if err := v.loadEntities(ctx, []types.ManagedObjectReference{*ref}); err != nil {
+		return "", err
+	}
+	return v.inventory.entities[*ref].Name, nil
+}
+
+// vmMetadata returns the folder path, resource pool and vApp of the VM
+func (v *VmwareProvider) vmMetadata(ctx context.Context, vm mo.VirtualMachine) map[string]string {
+	metadata := make(map[string]string)
+	vapp := vm.ParentVApp
+	if vapp == nil && vm.Parent != nil && vm.Parent.Type == "VirtualApp" {
+		vapp = vm.Parent
+	}
+	if vapp != nil {
+		if name, err := v.entityName(ctx, vapp); err == nil && name != "" {
+			metadata[sync.MetaVApp] = name
+		} else if err != nil {
+			v.log.Debug("could not retrieve vApp", "vm", vm.Self.Value, "error", err)
+		}
+	}
+	if vm.Parent != nil && vm.Parent.Type == "Folder" {
+		if path, err := v.folderPath(ctx, vm.Parent); err == nil {
+			metadata[sync.MetaFolder] = path
+		} else {
+			v.log.Debug("could not retrieve folder", "vm", vm.Self.Value, "error", err)
+		}
+	}
+	if vm.ResourcePool != nil && vm.ResourcePool.Type == "ResourcePool" {
+		if name, err := v.entityName(ctx, vm.ResourcePool); err == nil && name != "" {
+			metadata[sync.MetaResourcePool] = name
+		} else if err != nil {
+			v.log.Debug("could not retrieve resource pool", "vm", vm.Self.Value, "error", err)
+		}
+	}
+	return metadata
+}
+
+// isTemplate returns true if the VM is a template
+func isTemplate(vm mo.VirtualMachine) bool {
+	return vm.Config != nil && vm.Config.Template
+}
@@ -0,0 +1,155 @@
+package vmware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+	"github.com/ringsq/vcenterapi/pkg/vcenter"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// NIC backing types of the vcenter REST API
+const (
+	backingStandard    = "STANDARD_PORTGROUP"
+	backingDistributed = "DISTRIBUTED_PORTGROUP"
+	backingOpaque      = "OPAQUE_NETWORK"
+)
+
+// trunkVLAN is the VLAN ID of standard port groups that pass all VLANs to
+// the guest
+const trunkVLAN = 4095
+
+// portGroup is the network a NIC is connected to
+type portGroup struct {
+	Name   string
+	Switch string
+	// VLAN is the VLAN ID, 0 if untagged
+	VLAN int
+	// Trunk is true if the port group passes tagged VLANs to the guest
+	Trunk bool
+}
+
+// networks resolves the port groups of NICs, caching them for the rest of
+// the sync
+type networks struct {
+	portGroups map[types.ManagedObjectReference]portGroup
+	// hostPortGroups holds the standard port groups of each host by name
+	hostPortGroups map[types.ManagedObjectReference]map[string]types.HostPortGroupSpec
+}
+
+func newNetworks() *networks {
+	return &networks{
+		portGroups:     make(map[types.ManagedObjectReference]portGroup),
+		hostPortGroups: make(map[types.ManagedObjectReference]map[string]types.HostPortGroupSpec),
+	}
+}
+
+// vmNIC converts the vcenter NIC into a sync NIC with its port group,
+// switch, VLAN, connected state and adapter type
+func (v *VmwareProvider) vmNIC(ctx context.Context, nicID string, nic vcenter.NIC, host *types.ManagedObjectReference) sync.NIC {
+	connected := nic.State == "CONNECTED"
+	adapter := sync.NIC{
+		ID:      nicID,
+		Name:    nic.Label,
+		MAC:     strings.ToUpper(nic.MacAddress),
+		Type:    nic.Type,
+		Enabled: &connected,
+		Network: nic.Backing.Network,
+	}
+	pg, err := v.portGroup(ctx, nic.Backing.Type, nic.Backing.Network, host)
+	if err != nil {
+		v.log.Debug("could not retrieve port group", "network", nic.Backing.Network, "error", err)
+		return adapter
+	}
+	if pg.Name != "" {
+		adapter.Network = pg.Name
+	}
+	adapter.Switch = pg.Switch
+	adapter.VLAN = pg.VLAN
+	adapter.Tagged = pg.Trunk
+	return adapter
+}
+
+// portGroup returns the port group with the network ID of the backing type.
+// Standard port groups are looked up on the host of the VM.
+func (v *VmwareProvider) portGroup(ctx context.Context, backing string, networkID string, host *types.ManagedObjectReference) (portGroup, error) {
+	if v.soap == nil || networkID == "" {
+		return portGroup{}, nil
+	}
+	ref := types.ManagedObjectReference{Type: "Network", Value: networkID}
+	switch backing {
+	case backingDistributed:
+		ref.Type = "DistributedVirtualPortgroup"
+	case backingOpaque:
+		ref.Type = "OpaqueNetwork"
+	}
+	if pg, ok := v.networks.portGroups[ref]; ok && backing != backingStandard {
+		return pg, nil
+	}
+	pc := property.DefaultCollector(v.soap.Client)
+	pg := portGroup{}
+	switch backing {
+	case backingDistributed:
+		var dvpg mo.DistributedVirtualPortgroup
+		if err := pc.RetrieveOne(ctx, ref, []string{"name", "config"}, &dvpg); err != nil {
+			return pg, err
+		}
+		pg.Name = dvpg.Name
+		if setting, ok := dvpg.Config.DefaultPortConfig.(*types.VMwareDVSPortSetting); ok && setting != nil {
+			switch vlan := setting.Vlan.(type) {
+			case *types.VmwareDistributedVirtualSwitchVlanIdSpec:
+				pg.VLAN = int(vlan.VlanId)
+			case *types.VmwareDistributedVirtualSwitchTrunkVlanSpec, *types.VmwareDistributedVirtualSwitchPvlanSpec:
+				pg.Trunk = true
+			}
+		}
+		if dvpg.Config.DistributedVirtualSwitch != nil {
+			if name, err := v.entityName(ctx, dvpg.Config.DistributedVirtualSwitch); err == nil {
+				pg.Switch = name
+			}
+		}
+	default:
+		name, err := v.entityName(ctx, &ref)
+		if err != nil {
+			return pg, err
+		}
+		pg.Name = name
+		if backing == backingStandard && host != nil {
+			spec, err := v.hostPortGroup(ctx, *host, name)
+			if err != nil {
+				return pg, err
+			}
+			pg.Switch = spec.VswitchName
+			pg.VLAN = int(spec.VlanId)
+			if pg.VLAN == trunkVLAN {
+				pg.VLAN = 0
+				pg.Trunk = true
+			}
+		}
+	}
+	v.networks.portGroups[ref] = pg
+	return pg, nil
+}
+
+// hostPortGroup returns the standard port group of the host with the name
+func (v *VmwareProvider) hostPortGroup(ctx context.Context, host types.ManagedObjectReference, name string) (types.HostPortGroupSpec, error) {
+	portGroups, ok := v.networks.hostPortGroups[host]
+	if !ok {
+		var hs mo.HostSystem
+		pc := property.DefaultCollector(v.soap.Client)
+		if err := pc.RetrieveOne(ctx, host, []string{"config.network.portgroup"}, &hs); err != nil {
+			return types.HostPortGroupSpec{}, err
+		}
+		portGroups = make(map[string]types.HostPortGroupSpec)
+		if hs.Config != nil && hs.Config.Network != nil {
+			for _, pg := range hs.Config.Network.Portgroup {
+				portGroups[pg.Spec.Name] = pg.Spec
+			}
+		}
+		v.networks.hostPortGroups[host] = portGroups
+	}
+	return portGroups[name], nil
+}
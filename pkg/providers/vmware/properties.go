@@ -0,0 +1,53 @@
+package vmware
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// connectSOAP logs into the vSphere web services API, which provides the VM
+// properties that the vcenter REST API does not (eg. annotations)
+func connectSOAP(ctx context.Context, baseURL string, username string, password string) (*govmomi.Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/sdk"
+	u.User = url.UserPassword(username, password)
+	return govmomi.NewClient(ctx, u, true)
+}
+
+// vmProperties retrieves the given properties for all of the VM IDs in a
+// single call.  The results are keyed by VM ID.
+func (v *VmwareProvider) vmProperties(ctx context.Context, vmIDs []string, props ...string) (map[string]mo.VirtualMachine, error) {
+	result := make(map[string]mo.VirtualMachine)
+	if v.soap == nil || len(vmIDs) == 0 {
+		return result, nil
+	}
+	refs := make([]types.ManagedObjectReference, 0, len(vmIDs))
+	for _, id := range vmIDs {
+		refs = append(refs, types.ManagedObjectReference{Type: "VirtualMachine", Value: id})
+	}
+	var vms []mo.VirtualMachine
+	pc := property.DefaultCollector(v.soap.Client)
+	if err := pc.Retrieve(ctx, refs, props, &vms); err != nil {
+		return result, err
+	}
+	for _, vm := range vms {
+		result[vm.Self.Value] = vm
+	}
+	return result, nil
+}
+
+// annotation returns the notes of the VM
+func annotation(vm mo.VirtualMachine) string {
+	if vm.Config == nil {
+		return ""
+	}
+	return vm.Config.Annotation
+}
@@ -0,0 +1,33 @@
+package vmware
+
+import (
+	"strconv"
+
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "vmware",
+		Description: "VMware vCenter, PROVIDER_URL is the vCenter URL",
+		Settings: []providers.Setting{
+			{Name: "VMWARE_CONCURRENCY", Default: strconv.Itoa(defaultConcurrency), Description: "the number of VM details retrieved in parallel"},
+			{Name: "VMWARE_RETRIES", Default: strconv.Itoa(defaultRetries), Description: "the number of times retrieving the details of a VM is retried"},
+		},
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			concurrency, err := cfg.Int("VMWARE_CONCURRENCY")
+			if err != nil {
+				return nil, err
+			}
+			retries, err := cfg.Int("VMWARE_RETRIES")
+			if err != nil {
+				return nil, err
+			}
+			return NewVmwareProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger,
+				WithConcurrency(concurrency),
+				WithRetries(retries),
+			)
+		},
+	})
+}
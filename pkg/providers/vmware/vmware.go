@@ -1,13 +1,14 @@
 package vmware
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"strings"
 
 	"github.com/ringsq/netboxvmsync/pkg"
 	"github.com/ringsq/netboxvmsync/pkg/sync"
 	"github.com/ringsq/vcenterapi/pkg/vcenter"
+	"github.com/vmware/govmomi"
 )
 
 const VM_STATUS_ON = "POWERED_ON"
@@ -15,16 +16,31 @@ const VM_STATUS_ON = "POWERED_ON"
 var _ sync.VMProvider = (*VmwareProvider)(nil)
 
 type VmwareProvider struct {
-	vcenter *vcenter.Vcenter
-	log     pkg.Logger
+	vcenter   *vcenter.Vcenter
+	soap      *govmomi.Client
+	inventory *inventory
+	networks  *networks
+	log       pkg.Logger
+	// concurrency and retries control how the VM details are retrieved
+	concurrency int
+	retries     int
 }
 
 // NewVmwareProvider creates a new VM sync provider using vmware vcenter
-func NewVmwareProvider(baseURL string, username string, password string, logger pkg.Logger) (*VmwareProvider, error) {
-	vmw := &VmwareProvider{log: logger}
+func NewVmwareProvider(baseURL string, username string, password string, logger pkg.Logger, opts ...Option) (*VmwareProvider, error) {
+	vmw := &VmwareProvider{
+		log:         logger,
+		inventory:   newInventory(),
+		networks:    newNetworks(),
+		concurrency: defaultConcurrency,
+		retries:     defaultRetries,
+	}
 	if log, ok := logger.(*slog.Logger); ok {
 		vmw.log = log.With("provider", vmw.GetName())
 	}
+	for _, opt := range opts {
+		opt(vmw)
+	}
 	vmw.log.Info("Connecting...", "user", username)
 	vcntr, err := vcenter.NewClient(baseURL, username, password, vmw.log)
 	if err != nil {
@@ -33,6 +49,13 @@ func NewVmwareProvider(baseURL string, username string, password string, logger
 	}
 	vmw.vcenter = vcntr
 
+	soap, err := connectSOAP(context.Background(), baseURL, username, password)
+	if err != nil {
+		vmw.log.Warn("could not connect to the vsphere web services API, VM annotations, folders and resource pools will not be synced", "error", err)
+	} else {
+		vmw.soap = soap
+	}
+
 	return vmw, nil
 }
 
@@ -82,16 +105,49 @@ func (v *VmwareProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 		v.log.Error("could not list VMs", "error", err)
 		return vms, err
 	}
+	ids := make([]string, 0, len(vcVMs))
 	for _, listVM := range vcVMs {
-		vmDetail := sync.VM{}
-		vm, err := v.vcenter.GetVM(listVM.ID)
-		if err != nil {
-			v.log.Error("failed to get VM details", "error", err)
+		ids = append(ids, listVM.ID)
+	}
+	ctx := context.Background()
+	props, err := v.vmProperties(ctx, ids, append([]string{"config.annotation", "runtime.host"}, vmInventoryProps...)...)
+	if err != nil {
+		v.log.Warn("could not retrieve VM annotations, folders and resource pools", "error", err)
+	}
+	detailIDs := make([]string, 0, len(vcVMs))
+	for _, listVM := range vcVMs {
+		if !isTemplate(props[listVM.ID]) {
+			detailIDs = append(detailIDs, listVM.ID)
+		}
+	}
+	details := v.getVMDetails(detailIDs)
+	for _, listVM := range vcVMs {
+		if isTemplate(props[listVM.ID]) {
+			v.log.Debug("skipping template", "vm", listVM.Name)
+			continue
 		}
+		vmDetail := sync.VM{}
 		vmDetail.ID = listVM.ID
 		vmDetail.Name = listVM.Name
+		result := details[listVM.ID]
+		if result.err != nil {
+			v.log.Error("failed to get VM details", "vm", listVM.Name, "error", result.err)
+			vmDetail.Err = result.err
+			vms = append(vms, vmDetail)
+			continue
+		}
+		vm := result.detail
 		vmDetail.VCPUs = float32(listVM.CPUCount)
 		vmDetail.Memory = listVM.MemorySizeMiB
+		vmDetail.Description = annotation(props[listVM.ID])
+		if vmProps, ok := props[listVM.ID]; ok {
+			vmDetail.Metadata = v.vmMetadata(ctx, vmProps)
+		}
+		vmDetail.Serial = vm.Identity.BiosUUID
+		if vmDetail.Serial == "" {
+			vmDetail.Serial = vm.Identity.InstanceUUID
+		}
+		vmDetail.PowerState = listVM.PowerState
 		if listVM.PowerState == VM_STATUS_ON {
 			vmDetail.Status = "active"
 		} else {
@@ -105,11 +161,7 @@ func (v *VmwareProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
 		}
 		vmDetail.Network = make([]sync.NIC, 0)
 		for nicID, nic := range vm.Nics {
-			adapter := sync.NIC{}
-			adapter.ID = nicID
-			adapter.MAC = strings.ToUpper(nic.MacAddress)
-			adapter.Name = nic.Label
-			adapter.Description = fmt.Sprintf("%s %s to %s/%s", nic.Type, nic.State, nic.Backing.Type, nic.Backing.Network)
+			adapter := v.vmNIC(ctx, nicID, nic, props[listVM.ID].Runtime.Host)
 			for _, intf := range vm.VMinterfaces {
 				if intf.MacAddress == nic.MacAddress {
 					for _, ip := range intf.IP.IPAddresses {
@@ -0,0 +1,73 @@
+package xenorchestra
+
+// pool is an XCP-ng pool
+type pool struct {
+	ID              string `json:"id"`
+	NameLabel       string `json:"name_label"`
+	NameDescription string `json:"name_description"`
+}
+
+var poolFields = []string{"id", "name_label", "name_description"}
+
+// vm is an XCP-ng VM
+type vm struct {
+	ID              string `json:"id"`
+	UUID            string `json:"uuid"`
+	NameLabel       string `json:"name_label"`
+	NameDescription string `json:"name_description"`
+	PowerState      string `json:"power_state"`
+	Pool            string `json:"$pool"`
+	CPUs            struct {
+		Max    int `json:"max"`
+		Number int `json:"number"`
+	} `json:"CPUs"`
+	Memory struct {
+		Size int64 `json:"size"`
+	} `json:"memory"`
+	VBDs []string `json:"$VBDs"`
+	VIFs []string `json:"VIFs"`
+	// Addresses are the addresses reported by the guest tools, keyed by
+	// <VIF device>/<ipv4|ipv6>/<index>
+	Addresses map[string]string `json:"addresses"`
+	Tags      []string          `json:"tags"`
+}
+
+var vmFields = []string{"id", "uuid", "name_label", "name_description", "power_state", "$pool", "CPUs", "memory", "$VBDs", "VIFs", "addresses", "tags"}
+
+// vif is a virtual network interface
+type vif struct {
+	ID       string `json:"id"`
+	Device   string `json:"device"`
+	MAC      string `json:"MAC"`
+	Network  string `json:"$network"`
+	Attached bool   `json:"attached"`
+}
+
+var vifFields = []string{"id", "device", "MAC", "$network", "attached"}
+
+// vbd is the attachment of a VDI to a VM
+type vbd struct {
+	ID      string `json:"id"`
+	VDI     string `json:"VDI"`
+	Device  string `json:"device"`
+	IsCDROM bool   `json:"is_cd_rom"`
+}
+
+var vbdFields = []string{"id", "VDI", "device", "is_cd_rom"}
+
+// vdi is a virtual disk image
+type vdi struct {
+	ID              string `json:"id"`
+	NameLabel       string `json:"name_label"`
+	NameDescription string `json:"name_description"`
+	Size            int64  `json:"size"`
+}
+
+var vdiFields = []string{"id", "name_label", "name_description", "size"}
+
+type network struct {
+	ID        string `json:"id"`
+	NameLabel string `json:"name_label"`
+}
+
+var networkFields = []string{"id", "name_label"}
@@ -0,0 +1,16 @@
+package xenorchestra
+
+import (
+	"github.com/ringsq/netboxvmsync/pkg/providers"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+func init() {
+	providers.Register(providers.Registration{
+		Name:        "xenorchestra",
+		Description: "XCP-ng pools through Xen Orchestra, PROVIDER_TOKEN is an authentication token",
+		New: func(cfg providers.Config) (sync.VMProvider, error) {
+			return NewXenOrchestraProvider(cfg.URL, cfg.User, cfg.Token, cfg.Logger)
+		},
+	})
+}
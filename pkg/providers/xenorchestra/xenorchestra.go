@@ -0,0 +1,211 @@
+// Package xenorchestra syncs the VMs of XCP-ng pools managed by Xen
+// Orchestra using its REST API.
+package xenorchestra
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ringsq/netboxvmsync/pkg"
+	"github.com/ringsq/netboxvmsync/pkg/sync"
+)
+
+var _ sync.VMProvider = (*XenOrchestraProvider)(nil)
+
+const mb = 1048576
+const gb = 1073741824
+
+type XenOrchestraProvider struct {
+	client *client
+	log    pkg.Logger
+	// name is the name of the Xen Orchestra datacenter
+	name  string
+	pools []pool
+	// the objects below are loaded once and cached for the sync run
+	vms      []vm
+	vifs     map[string]vif
+	vbds     map[string]vbd
+	vdis     map[string]vdi
+	networks map[string]network
+}
+
+// NewXenOrchestraProvider creates a new VM sync provider for Xen
+// Orchestra.  baseURL is the Xen Orchestra URL and password is an
+// authentication token created with xo-cli or in the user settings.
+func NewXenOrchestraProvider(baseURL string, username string, password string, logger pkg.Logger) (*XenOrchestraProvider, error) {
+	xo := &XenOrchestraProvider{client: newClient(baseURL, password), log: logger}
+	if log, ok := logger.(*slog.Logger); ok {
+		xo.log = log.With("provider", xo.GetName())
+	}
+	xo.name = baseURL
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		xo.name = u.Hostname()
+	}
+	xo.log.Info("Connecting...", "url", baseURL)
+	if err := xo.client.list(context.Background(), "pools", poolFields, &xo.pools); err != nil {
+		return xo, err
+	}
+	xo.log.Info("connected to xen orchestra", "pools", len(xo.pools))
+	return xo, nil
+}
+
+func (xo *XenOrchestraProvider) GetName() string {
+	return "Xen Orchestra"
+}
+
+// GetDatacenters returns a list of all datacenters managed by this provider.
+// Xen Orchestra is the only datacenter.
+func (xo *XenOrchestraProvider) GetDatacenters() ([]sync.Datacenter, error) {
+	dc := sync.Datacenter{ID: xo.name, Name: xo.name, Description: "Xen Orchestra"}
+	return []sync.Datacenter{dc}, nil
+}
+
+// GetDcClusters gets a list of clusters for the given datacenter ID.  Every
+// pool is a cluster.
+func (xo *XenOrchestraProvider) GetDcClusters(datacenterID string) ([]sync.Cluster, error) {
+	clusters := make([]sync.Cluster, 0, len(xo.pools))
+	for _, p := range xo.pools {
+		clusters = append(clusters, sync.Cluster{ID: p.ID, Name: p.NameLabel, Description: p.NameDescription})
+	}
+	return clusters, nil
+}
+
+// GetClusterVMs returns a list of VMs for the given cluster ID
+func (xo *XenOrchestraProvider) GetClusterVMs(clusterID string) ([]sync.VM, error) {
+	if err := xo.load(context.Background()); err != nil {
+		return nil, err
+	}
+	vms := make([]sync.VM, 0)
+	for _, v := range xo.vms {
+		if v.Pool == clusterID {
+			vms = append(vms, xo.toVM(v))
+		}
+	}
+	return vms, nil
+}
+
+// load retrieves the VMs and their devices of all pools, unless they have
+// already been loaded
+func (xo *XenOrchestraProvider) load(ctx context.Context) error {
+	if xo.vms != nil {
+		return nil
+	}
+	vifs, vbds, vdis, networks := []vif{}, []vbd{}, []vdi{}, []network{}
+	for _, collection := range []struct {
+		name   string
+		fields []string
+		result any
+	}{
+		{"vifs", vifFields, &vifs},
+		{"vbds", vbdFields, &vbds},
+		{"vdis", vdiFields, &vdis},
+		{"networks", networkFields, &networks},
+	} {
+		if err := xo.client.list(ctx, collection.name, collection.fields, collection.result); err != nil {
+			return fmt.Errorf("could not retrieve %s: %w", collection.name, err)
+		}
+	}
+	xo.vifs = make(map[string]vif)
+	for _, v := range vifs {
+		xo.vifs[v.ID] = v
+	}
+	xo.vbds = make(map[string]vbd)
+	for _, v := range vbds {
+		xo.vbds[v.ID] = v
+	}
+	xo.vdis = make(map[string]vdi)
+	for _, v := range vdis {
+		xo.vdis[v.ID] = v
+	}
+	xo.networks = make(map[string]network)
+	for _, n := range networks {
+		xo.networks[n.ID] = n
+	}
+	vms := make([]vm, 0)
+	if err := xo.client.list(ctx, "vms", vmFields, &vms); err != nil {
+		return fmt.Errorf("could not retrieve VMs: %w", err)
+	}
+	xo.vms = vms
+	return nil
+}
+
+// toVM converts the XCP-ng VM
+func (xo *XenOrchestraProvider) toVM(v vm) sync.VM {
+	result := sync.VM{ID: v.UUID, Name: v.NameLabel, Type: sync.VMTypeVirtualMachine}
+	result.Description = v.NameDescription
+	// XCP-ng uses the VM UUID as the SMBIOS UUID
+	result.Serial = v.UUID
+	result.VCPUs = float32(v.CPUs.Number)
+	result.Memory = int(v.Memory.Size / mb)
+	result.Tags = v.Tags
+	result.PowerState = v.PowerState
+	if v.PowerState == "Running" {
+		result.Status = "active"
+	} else {
+		result.Status = "offline"
+	}
+	var diskspace int64
+	for _, id := range v.VBDs {
+		b, ok := xo.vbds[id]
+		if !ok || b.IsCDROM {
+			continue
+		}
+		d, ok := xo.vdis[b.VDI]
+		if !ok {
+			continue
+		}
+		name := b.Device
+		if name == "" {
+			name = d.NameLabel
+		}
+		result.Disks = append(result.Disks, sync.Disk{ID: d.ID, Name: name, Size: d.Size, Description: d.NameLabel})
+		diskspace += d.Size
+	}
+	result.Diskspace = int(diskspace / gb)
+	result.Network = make([]sync.NIC, 0, len(v.VIFs))
+	for _, id := range v.VIFs {
+		vf, ok := xo.vifs[id]
+		if !ok {
+			continue
+		}
+		nic := sync.NIC{ID: vf.ID, Name: "eth" + vf.Device, MAC: strings.ToUpper(vf.MAC)}
+		nic.Network = xo.networks[vf.Network].NameLabel
+		attached := vf.Attached
+		nic.Enabled = &attached
+		for _, ip := range vifAddresses(v.Addresses, vf.Device) {
+			nic.AddIP(hostAddress(ip), sync.IPSourceAgent)
+		}
+		result.Network = append(result.Network, nic)
+	}
+	return result
+}
+
+// vifAddresses returns the addresses reported by the guest tools for the
+// VIF device, sorted by their key
+func vifAddresses(addresses map[string]string, device string) []string {
+	keys := make([]string, 0)
+	for key := range addresses {
+		if strings.HasPrefix(key, device+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	ips := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ips = append(ips, addresses[key])
+	}
+	return ips
+}
+
+// hostAddress returns the address as a host route, /32 or /128
+func hostAddress(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field owners decide whether the provider or Netbox is the source of
+// truth for a VM field.  Fields owned by Netbox are set when the VM is
+// created but never overwritten by the sync.
+const (
+	OwnerProvider = "provider"
+	OwnerNetbox   = "netbox"
+)
+
+// maxDescription is the length limit of the Netbox description field
+const maxDescription = 200
+
+// OwnedFields lists the VM fields whose ownership can be configured
+var OwnedFields = []string{"description", "comments", "serial", "status", "memory", "vcpus", "disk", "role", "tenant"}
+
+// ParseFieldOwnership converts a comma separated list of field:owner pairs
+// (eg. description:netbox,comments:provider) into a field ownership map.
+// Fields that are not listed are owned by the provider.
+func ParseFieldOwnership(ownership string) (map[string]string, error) {
+	owners := make(map[string]string)
+	for _, pair := range strings.Split(ownership, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, owner, ok := strings.Cut(pair, ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		owner = strings.ToLower(strings.TrimSpace(owner))
+		if !ok || (owner != OwnerProvider && owner != OwnerNetbox) {
+			return nil, fmt.Errorf("invalid field ownership %q, expected field:%s or field:%s", pair, OwnerProvider, OwnerNetbox)
+		}
+		known := false
+		for _, f := range OwnedFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(OwnedFields, ", "))
+		}
+		owners[field] = owner
+	}
+	return owners, nil
+}
+
+// ownsField returns true if the sync may overwrite the field in Netbox
+func (s *Sync) ownsField(field string) bool {
+	return s.fieldOwners[field] != OwnerNetbox
+}
+
+// noteDescription returns the first non-empty line of the provider notes,
+// truncated to the Netbox description limit
+func noteDescription(notes string) string {
+	for _, line := range strings.Split(notes, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if runes := []rune(line); len(runes) > maxDescription {
+			line = string(runes[:maxDescription])
+		}
+		return line
+	}
+	return ""
+}
+
+// noteComments returns the full provider notes for the Netbox comments
+func noteComments(notes string) string {
+	return strings.TrimSpace(strings.ReplaceAll(notes, "\r\n", "\n"))
+}
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rsapc/netbox"
+)
+
+// Netbox 802.1Q interface modes
+const (
+	modeAccess    = "access"
+	modeTaggedAll = "tagged-all"
+)
+
+// interfaceDescription returns the description of the NIC, built from its
+// adapter type, network, switch and VLAN when the provider does not give one
+func interfaceDescription(nic NIC) string {
+	if nic.Description != "" || nic.Network == "" {
+		return nic.Description
+	}
+	desc := nic.Network
+	if nic.Switch != "" {
+		desc = fmt.Sprintf("%s (%s)", desc, nic.Switch)
+	}
+	if nic.Type != "" {
+		desc = fmt.Sprintf("%s on %s", nic.Type, desc)
+	}
+	switch {
+	case nic.Tagged:
+		desc += ", trunk"
+	case nic.VLAN > 0:
+		desc += fmt.Sprintf(", VLAN %d", nic.VLAN)
+	}
+	return desc
+}
+
+// interfaceSettings returns the description, enabled state, mode and
+// untagged VLAN the interface of the Netbox VM should have for the NIC
+func (s *Sync) interfaceSettings(vmID int, nic NIC) map[string]any {
+	settings := make(map[string]any)
+	if desc := interfaceDescription(nic); desc != "" {
+		settings["description"] = desc
+	}
+	if nic.Enabled != nil {
+		settings["enabled"] = *nic.Enabled
+	}
+	switch {
+	case nic.Tagged:
+		settings["mode"] = modeTaggedAll
+	case nic.VLAN > 0:
+		vlanID, err := s.getVLANID(vmID, nic.VLAN, nic.Network)
+		if err != nil {
+			s.log.Warn("not linking interface to VLAN", "vlan", nic.VLAN, "network", nic.Network, "error", err)
+			break
+		}
+		settings["mode"] = modeAccess
+		settings["untagged_vlan"] = vlanID
+	}
+	return settings
+}
+
+// changedInterfaceSettings returns the settings that differ from the
+// Netbox interface of the VM
+func (s *Sync) changedInterfaceSettings(vmID int, nbint netbox.Interface, nic NIC) map[string]any {
+	changed := make(map[string]any)
+	for key, value := range s.interfaceSettings(vmID, nic) {
+		switch key {
+		case "description":
+			if nbint.Description != value {
+				changed[key] = value
+			}
+		case "enabled":
+			if nbint.Enabled != value {
+				changed[key] = value
+			}
+		case "mode":
+			if !strings.EqualFold(nestedValue(nbint.Mode, "value"), fmt.Sprint(value)) {
+				changed[key] = value
+			}
+		case "untagged_vlan":
+			if nestedValue(nbint.UntaggedVlan, "id") != fmt.Sprint(value) {
+				changed[key] = value
+			}
+		}
+	}
+	return changed
+}
+
+// nestedValue returns the field of a nested Netbox object (eg. the value
+// of a choice or the id of a related object) as a string
+func nestedValue(obj interface{}, field string) string {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, ok := m[field]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,281 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/rsapc/netbox"
+)
+
+// DefaultMatchOrder is the order the matchers are tried in when looking
+// for the Netbox VM of a provider VM
+var DefaultMatchOrder = []string{"id", "serial", "mac", "name"}
+
+var ErrAmbiguousMatch = errors.New("more than one Netbox VM matched")
+
+// VMMatcher returns the Netbox VMs that could be the given provider VM.
+// The provider VM is being synced into nbCluster.
+type VMMatcher func(s *Sync, nbCluster netbox.Cluster, vm VM) ([]VMCluster, error)
+
+var matchers = map[string]VMMatcher{
+	"id":     matchByID,
+	"serial": matchBySerial,
+	"mac":    matchByMAC,
+	"name":   matchByName,
+}
+
+// RegisterMatcher adds a matcher that can be used in the match order
+func RegisterMatcher(name string, matcher VMMatcher) {
+	matchers[strings.ToLower(name)] = matcher
+}
+
+// ParseMatchOrder converts a comma separated list of matcher names into a
+// match order.  An empty string returns the DefaultMatchOrder.  The id
+// matcher always runs first whether it is listed or not: the other matchers
+// skip the VMs that already belong to a provider, so without it every VM
+// synced before would be created again.  Matchers may only be listed once.
+func ParseMatchOrder(order string) ([]string, error) {
+	if strings.TrimSpace(order) == "" {
+		return DefaultMatchOrder, nil
+	}
+	names := []string{"id"}
+	listed := make(map[string]bool)
+	for _, name := range strings.Split(order, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := matchers[name]; !ok {
+			return nil, fmt.Errorf("unknown VM matcher %q", name)
+		}
+		if listed[name] {
+			return nil, fmt.Errorf("duplicate VM matcher %q", name)
+		}
+		listed[name] = true
+		if name != "id" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// AmbiguousMatch records a provider VM that matched more than one Netbox VM
+type AmbiguousMatch struct {
+	Cluster    string
+	VM         string
+	Matcher    string
+	Candidates []string
+}
+
+// MatchVM runs the provider VM through the matchers in the configured order
+// and returns the Netbox VM it belongs to.  VMs adopted by any matcher other
+// than the provider ID are tagged with the provider ID, and VMs found in
+// another cluster are moved into nbCluster.  Matchers that find more than
+// one VM are only reported if no later matcher finds a single one.
+func (s *Sync) MatchVM(nbCluster netbox.Cluster, vm VM) (NBVM, error) {
+	ambiguous := make([]AmbiguousMatch, 0)
+	for _, name := range s.matchOrder {
+		candidates, err := matchers[name](s, nbCluster, vm)
+		if err != nil {
+			s.log.Warn("VM matcher failed", "matcher", name, "vm", vm.Name, "error", err)
+			continue
+		}
+		if name != "id" {
+			candidates = unownedVMs(candidates)
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		if len(candidates) > 1 {
+			ambiguous = append(ambiguous, newAmbiguousMatch(nbCluster, vm, name, candidates))
+			continue
+		}
+		match := candidates[0]
+		if name != "id" {
+			s.log.Info("adopting existing Netbox VM", "vm", vm.Name, "netbox", match.Name, "matcher", name)
+			if err = s.setIDandProvider(match.URL, vm.ID); err != nil {
+				return NBVM{}, err
+			}
+		}
+		if match.Cluster.ID != nbCluster.ID {
+			if err = s.moveVM(match, nbCluster); err != nil {
+				return NBVM{}, err
+			}
+		}
+		return s.GetVMbyID(match.ID)
+	}
+	if len(ambiguous) > 0 {
+		s.ambiguous = append(s.ambiguous, ambiguous...)
+		return NBVM{}, ErrAmbiguousMatch
+	}
+	return NBVM{}, netbox.ErrNotFound
+}
+
+// unownedVMs removes the Netbox VMs that already belong to a provider VM,
+// of this or any other provider, so two syncs never fight over a VM
+func unownedVMs(candidates []VMCluster) []VMCluster {
+	vms := make([]VMCluster, 0)
+	for _, candidate := range candidates {
+		if customFieldSet(candidate.CustomFields["vmid"]) || customFieldSet(candidate.CustomFields["vmprovider"]) {
+			continue
+		}
+		vms = append(vms, candidate)
+	}
+	return vms
+}
+
+// customFieldSet reports whether the custom field value is neither null nor empty
+func customFieldSet(value any) bool {
+	return value != nil && fmt.Sprint(value) != ""
+}
+
+func newAmbiguousMatch(nbCluster netbox.Cluster, vm VM, matcher string, candidates []VMCluster) AmbiguousMatch {
+	match := AmbiguousMatch{Cluster: nbCluster.Name, VM: vm.Name, Matcher: matcher}
+	for _, candidate := range candidates {
+		match.Candidates = append(match.Candidates, fmt.Sprintf("%s (%s)", candidate.Name, candidate.Cluster.Name))
+	}
+	return match
+}
+
+// ReportAmbiguousMatches logs every provider VM that matched more than one
+// Netbox VM during the sync
+func (s *Sync) ReportAmbiguousMatches() {
+	for _, match := range s.ambiguous {
+		s.log.Warn("ambiguous Netbox VM match", "cluster", match.Cluster, "vm", match.VM, "matcher", match.Matcher, "candidates", strings.Join(match.Candidates, ", "))
+	}
+	if len(s.ambiguous) > 0 {
+		s.log.Warn("VMs with ambiguous matches were not synced", "count", len(s.ambiguous))
+	}
+}
+
+// matchByID finds the VM by the provider ID.  A VM in the current cluster
+// always wins; otherwise the VM is looked up in the other clusters of the
+// provider, where the name has to agree as well since provider IDs are not
+// always unique across clusters (eg. Proxmox VMIDs).
+func matchByID(s *Sync, nbCluster netbox.Cluster, vm VM) ([]VMCluster, error) {
+	vms, err := s.searchVMClusters(
+		fmt.Sprintf("cluster_type=%s", url.QueryEscape(netbox.Slugify(s.vmProvider.GetName()))),
+		fmt.Sprintf("cf_vmid=%s", url.QueryEscape(vm.ID)),
+		fmt.Sprintf("cf_vmprovider=%s", url.QueryEscape(s.vmProvider.GetName())),
+	)
+	if err != nil {
+		return nil, err
+	}
+	local := make([]VMCluster, 0)
+	moved := make([]VMCluster, 0)
+	for _, candidate := range vms {
+		if fmt.Sprint(candidate.CustomFields["vmid"]) != vm.ID {
+			continue
+		}
+		if candidate.Cluster.ID == nbCluster.ID {
+			local = append(local, candidate)
+		} else if strings.EqualFold(candidate.Name, vm.Name) {
+			moved = append(moved, candidate)
+		} else {
+			s.log.Debug("VM id found in another cluster with a different name", "vm", vm.Name, "netbox", candidate.Name, "cluster", candidate.Cluster.Name)
+		}
+	}
+	if len(local) > 0 {
+		return local, nil
+	}
+	return moved, nil
+}
+
+// moveVM assigns the Netbox VM to the given cluster and records the
+// migration in the VM's journal
+func (s *Sync) moveVM(vm VMCluster, nbCluster netbox.Cluster) error {
+	s.log.Info("VM migrated between clusters", "vm", vm.Name, "from", vm.Cluster.Name, "to", nbCluster.Name)
+	if err := s.netbox.UpdateObjectByURL(vm.URL, map[string]any{"cluster": nbCluster.ID}); err != nil {
+		s.log.Error("could not move VM to new cluster", "vm", vm.Name, "cluster", nbCluster.Name, "error", err)
+		return err
+	}
+	if err := s.netbox.AddJournalEntry("virtualmachine", int64(vm.ID), netbox.InfoLevel,
+		"Moved from cluster %s to %s by %s sync", vm.Cluster.Name, nbCluster.Name, s.vmProvider.GetName()); err != nil {
+		s.log.Warn("could not add journal entry for moved VM", "vm", vm.Name, "error", err)
+	}
+	return nil
+}
+
+// matchBySerial finds the VM by its BIOS UUID or serial number
+func matchBySerial(s *Sync, nbCluster netbox.Cluster, vm VM) ([]VMCluster, error) {
+	if vm.Serial == "" {
+		return nil, nil
+	}
+	return s.searchVMClusters(fmt.Sprintf("serial__ie=%s", url.QueryEscape(vm.Serial)))
+}
+
+// matchByMAC finds the VM that owns the MAC addresses of the provider VM's
+// interfaces
+func matchByMAC(s *Sync, nbCluster netbox.Cluster, vm VM) ([]VMCluster, error) {
+	args := make([]string, 0)
+	for _, nic := range vm.Network {
+		if nic.MAC != "" {
+			args = append(args, fmt.Sprintf("mac_address=%s", url.QueryEscape(nic.MAC)))
+		}
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	vmIDs := make(map[int]bool)
+	result := &VMInterfaceSearchResults{}
+	if err := s.netbox.Search("vminterface", result, args...); err != nil {
+		return nil, err
+	}
+	for {
+		for _, intf := range result.Results {
+			vmIDs[intf.VirtualMachine.ID] = true
+		}
+		if result.Next == nil {
+			break
+		}
+		next := *result.Next
+		result.Next = nil
+		if _, err := s.netbox.GetByURL(next, result); err != nil {
+			return nil, err
+		}
+	}
+	if len(vmIDs) == 0 {
+		return nil, nil
+	}
+	idArgs := make([]string, 0, len(vmIDs))
+	for id := range vmIDs {
+		idArgs = append(idArgs, fmt.Sprintf("id=%d", id))
+	}
+	return s.searchVMClusters(idArgs...)
+}
+
+// matchByName finds the VM in the cluster with the same normalized name, so
+// that a FQDN matches its short name regardless of case
+func matchByName(s *Sync, nbCluster netbox.Cluster, vm VM) ([]VMCluster, error) {
+	name := NormalizeName(vm.Name)
+	if name == "" {
+		return nil, nil
+	}
+	vms, err := s.searchVMClusters(
+		fmt.Sprintf("cluster_id=%d", nbCluster.ID),
+		fmt.Sprintf("name__isw=%s", url.QueryEscape(name)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]VMCluster, 0)
+	for _, candidate := range vms {
+		if NormalizeName(candidate.Name) == name {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches, nil
+}
+
+// NormalizeName lowercases the VM name and strips the domain from FQDNs
+func NormalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if net.ParseIP(name) != nil {
+		return name
+	}
+	host, _, _ := strings.Cut(name, ".")
+	return host
+}
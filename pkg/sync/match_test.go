@@ -0,0 +1,63 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMatchOrder(t *testing.T) {
+	tests := []struct {
+		order    string
+		expected []string
+		err      string
+	}{
+		{order: "", expected: DefaultMatchOrder},
+		{order: " , ", expected: []string{"id"}},
+		{order: "name,mac", expected: []string{"id", "name", "mac"}},
+		{order: "serial,id,name", expected: []string{"id", "serial", "name"}},
+		{order: " MAC , Serial ", expected: []string{"id", "mac", "serial"}},
+		{order: "id", expected: []string{"id"}},
+		{order: "name,uuid", err: `unknown VM matcher "uuid"`},
+		{order: "name,mac,Name", err: `duplicate VM matcher "name"`},
+		{order: "id,serial,id", err: `duplicate VM matcher "id"`},
+	}
+	for _, test := range tests {
+		order, err := ParseMatchOrder(test.order)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: error %v, expected %s", test.order, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(order, test.expected) {
+			t.Errorf("%q: %v, %v, expected %v", test.order, order, err, test.expected)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"web1", "web1"},
+		{"WEB1", "web1"},
+		{" Web1.Example.COM ", "web1"},
+		{"web1.example.com.", "web1"},
+		{"apps/web1", "apps/web1"},
+		{"10.0.0.5", "10.0.0.5"},
+		{"2001:DB8::5", "2001:db8::5"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if name := NormalizeName(test.name); name != test.expected {
+			t.Errorf("%q normalized to %q, expected %q", test.name, name, test.expected)
+		}
+	}
+	if NormalizeName("web1.example.com") != NormalizeName("WEB1") {
+		t.Error("an FQDN and its short name in another case do not match")
+	}
+	if NormalizeName("web1") == NormalizeName("web10") {
+		t.Error("different names match")
+	}
+}
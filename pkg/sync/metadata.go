@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Metadata keys set by the providers
+const (
+	// MetaPool is the Proxmox resource pool of the VM
+	MetaPool = "pool"
+	// MetaHAGroup is the Proxmox HA group of the VM
+	MetaHAGroup = "ha_group"
+	// MetaHAState is the requested Proxmox HA state of the VM
+	MetaHAState = "ha_state"
+	// MetaFolder is the VMware VM folder path of the VM, eg. /Prod/Web
+	MetaFolder = "folder"
+	// MetaResourcePool is the VMware resource pool of the VM
+	MetaResourcePool = "resource_pool"
+	// MetaVApp is the VMware vApp of the VM
+	MetaVApp = "vapp"
+	// MetaProject is the OpenStack project of the VM
+	MetaProject = "project"
+	// MetaZone is the availability zone of the VM
+	MetaZone = "zone"
+	// MetaNamespace is the Kubernetes namespace of the VM
+	MetaNamespace = "namespace"
+	// MetaVPC is the EC2 VPC of the VM
+	MetaVPC = "vpc"
+	// MetaInstanceType is the EC2 instance type of the VM
+	MetaInstanceType = "instance_type"
+)
+
+// Metadata targets decide what a metadata value is used for in Netbox.
+// Custom field targets are given as cf_<name>.
+const (
+	TargetTenant      = "tenant"
+	TargetRole        = "role"
+	TargetTag         = "tag"
+	TargetCustomField = "cf_"
+)
+
+// MetadataRule maps a provider metadata key to a Netbox target
+type MetadataRule struct {
+	Key    string
+	Target string
+}
+
+// CustomField returns the name of the custom field the rule targets, or an
+// empty string if it targets something else
+func (r MetadataRule) CustomField() string {
+	if name, ok := strings.CutPrefix(r.Target, TargetCustomField); ok {
+		return name
+	}
+	return ""
+}
+
+// ParseMetadataRules converts a comma separated list of key:target pairs
+// (eg. pool:tenant,ha_group:tag,ha_state:cf_ha_state) into metadata rules
+func ParseMetadataRules(rules string) ([]MetadataRule, error) {
+	parsed := make([]MetadataRule, 0)
+	for _, pair := range strings.Split(rules, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, target, ok := strings.Cut(pair, ":")
+		rule := MetadataRule{Key: strings.ToLower(strings.TrimSpace(key)), Target: strings.TrimSpace(target)}
+		if !ok || rule.Key == "" {
+			return nil, fmt.Errorf("invalid metadata rule %q, expected key:target", pair)
+		}
+		if rule.Target != TargetTenant && rule.Target != TargetRole && rule.Target != TargetTag && rule.CustomField() == "" {
+			return nil, fmt.Errorf("invalid metadata target %q, expected %s, %s, %s or %s<name>", rule.Target, TargetTenant, TargetRole, TargetTag, TargetCustomField)
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// MetadataFilter selects VMs by a metadata value.  The pattern is matched
+// with path.Match, so * does not match the / of folder paths.
+type MetadataFilter struct {
+	Key     string
+	Pattern string
+	// Exclude selects the VMs that do not match the pattern
+	Exclude bool
+}
+
+// Matches returns true if the VM is selected by the filter
+func (f MetadataFilter) Matches(vm VM) bool {
+	matched, _ := path.Match(f.Pattern, vm.Metadata[f.Key])
+	return matched != f.Exclude
+}
+
+// ParseMetadataFilters converts a comma separated list of key=pattern or
+// key!=pattern filters (eg. folder=/Prod/*,resource_pool!=Test) into
+// metadata filters
+func ParseMetadataFilters(filters string) ([]MetadataFilter, error) {
+	parsed := make([]MetadataFilter, 0)
+	for _, entry := range strings.Split(filters, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		key, pattern, ok := strings.Cut(entry, "=")
+		filter := MetadataFilter{}
+		if k, isExclude := strings.CutSuffix(key, "!"); isExclude {
+			key = k
+			filter.Exclude = true
+		}
+		filter.Key = strings.ToLower(strings.TrimSpace(key))
+		filter.Pattern = strings.TrimSpace(pattern)
+		if !ok || filter.Key == "" {
+			return nil, fmt.Errorf("invalid metadata filter %q, expected key=pattern or key!=pattern", entry)
+		}
+		if _, err := path.Match(filter.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid metadata filter pattern %q: %w", filter.Pattern, err)
+		}
+		parsed = append(parsed, filter)
+	}
+	return parsed, nil
+}
+
+// selected returns true if the VM matches all of the metadata filters
+func (s *Sync) selected(vm VM) bool {
+	for _, filter := range s.metadataFilters {
+		if !filter.Matches(vm) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyMetadata sets the tenant, tags and custom fields of the VM from its
+// metadata using the configured rules
+func (s *Sync) applyMetadata(vm *VM) {
+	for _, rule := range s.metadataRules {
+		value := vm.Metadata[rule.Key]
+		if value == "" {
+			continue
+		}
+		switch rule.Target {
+		case TargetTenant:
+			vm.Tenant = value
+		case TargetRole:
+			vm.Role = value
+		case TargetTag:
+			vm.Tags = append(vm.Tags, value)
+		default:
+			if vm.CustomFields == nil {
+				vm.CustomFields = make(map[string]any)
+			}
+			vm.CustomFields[rule.CustomField()] = value
+		}
+	}
+}
+
+// verifyMetadataFields ensures the custom fields targeted by the metadata
+// rules exist in Netbox
+func (s *Sync) verifyMetadataFields() error {
+	for _, rule := range s.metadataRules {
+		name := rule.CustomField()
+		if name == "" {
+			continue
+		}
+		field := CustomField{Name: name, Label: fmt.Sprintf("Provider %s", rule.Key), Types: []string{"virtualmachine"}}
+		if err := s.VerifyCustomField(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedCustomFields returns the VM custom fields whose value differs from
+// the Netbox VM
+func changedCustomFields(nbVM NBVM, vm VM) map[string]any {
+	changed := make(map[string]any)
+	for name, value := range vm.CustomFields {
+		current, ok := nbVM.CustomFieldsMap[name]
+		if !ok || current == nil || fmt.Sprint(current) != fmt.Sprint(value) {
+			changed[name] = value
+		}
+	}
+	return changed
+}
@@ -2,6 +2,7 @@ package sync
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/rsapc/netbox"
 )
@@ -39,6 +40,54 @@ type VM struct {
 	VCPUs       float32
 	Network     []NIC
 	Status      string
+	// PowerState is the native power state of the provider, eg. POWERED_ON
+	// or running, which can be mapped to a Netbox status with a status map
+	PowerState string
+	// Disks are the individual disks of the VM, Diskspace is their total
+	Disks []Disk
+	// Serial is the BIOS UUID or serial number of the VM
+	Serial string
+	// Type is the kind of guest, VMTypeVirtualMachine or VMTypeContainer
+	Type string
+	// Role is the name of the Netbox VM role to assign
+	Role string
+	// Tags are the names of Netbox tags to add to the VM
+	Tags []string
+	// Metadata holds provider specific values, eg. the resource pool, that
+	// can be mapped to the tenant, tags or custom fields with MetadataRules
+	Metadata map[string]string
+	// Tenant is the name of the Netbox tenant to assign
+	Tenant string
+	// CustomFields are Netbox custom field values to set on the VM
+	CustomFields map[string]any
+	// Err is set when the provider could not retrieve all of the VM
+	// details.  The VM is not synced, but is not pruned either.
+	Err error
+}
+
+// VM types
+const (
+	VMTypeVirtualMachine = "vm"
+	VMTypeContainer      = "container"
+)
+
+// VMEdit is used to update a VM with the fields
+// netbox.NewVM does not support
+type VMEdit struct {
+	netbox.NewVM
+	Serial      string  `json:"serial,omitempty"`
+	Role        int     `json:"role,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Comments    *string `json:"comments,omitempty"`
+}
+
+// Disk is a virtual disk of a VM
+type Disk struct {
+	ID   string
+	Name string
+	// Size is the size in bytes
+	Size        int64
+	Description string
 }
 
 type NIC struct {
@@ -47,6 +96,66 @@ type NIC struct {
 	MAC         string
 	IP          []string
 	Description string
+	// IPSources maps each address in IP to the source that reported it
+	IPSources map[string]string
+	// Type is the adapter type, eg. VMXNET3
+	Type string
+	// Network is the port group or network the NIC is connected to
+	Network string
+	// Switch is the virtual or distributed switch of the network
+	Switch string
+	// VLAN is the untagged VLAN ID of the network, 0 if untagged or unknown
+	VLAN int
+	// Tagged is true if the network passes tagged VLANs to the guest
+	Tagged bool
+	// Enabled is false if the NIC is disconnected, nil if unknown
+	Enabled *bool
+}
+
+// IP address sources
+const (
+	IPSourceAgent     = "agent"
+	IPSourceCloudInit = "cloud-init"
+	IPSourceConfig    = "config"
+)
+
+// AddIP adds the address reported by the source to the NIC.  An address
+// that is already present is replaced when reported by the guest agent,
+// as the agent knows the prefix the guest actually uses.
+func (n *NIC) AddIP(ip string, source string) {
+	if n.IPSources == nil {
+		n.IPSources = make(map[string]string)
+	}
+	host, _, _ := strings.Cut(ip, "/")
+	for i, existing := range n.IP {
+		existingHost, _, _ := strings.Cut(existing, "/")
+		if !strings.EqualFold(host, existingHost) {
+			continue
+		}
+		if source == IPSourceAgent && n.IPSources[existing] != IPSourceAgent {
+			delete(n.IPSources, existing)
+			n.IP[i] = ip
+			n.IPSources[ip] = source
+		}
+		return
+	}
+	n.IP = append(n.IP, ip)
+	n.IPSources[ip] = source
+}
+
+// PreferredIPs returns the addresses reported by the guest agent if there
+// are any, otherwise all addresses of the NIC
+func (n NIC) PreferredIPs() []string {
+	agentIPs := make([]string, 0)
+	for _, ip := range n.IP {
+		if n.IPSources[ip] == IPSourceAgent {
+			agentIPs = append(agentIPs, ip)
+		}
+	}
+	if len(agentIPs) > 0 {
+		return agentIPs
+	}
+	return n.IP
 }
 
 type VMProvider interface {
@@ -66,8 +175,55 @@ type NBVM struct {
 	IPs        []NetboxIP
 }
 
+// VMCluster is a Netbox VM along with the cluster it is assigned to
+type VMCluster struct {
+	ID           int                  `json:"id"`
+	URL          string               `json:"url"`
+	Name         string               `json:"name"`
+	Cluster      netbox.DisplayIDName `json:"cluster"`
+	CustomFields map[string]any       `json:"custom_fields"`
+}
+
+type VMClusterSearchResults struct {
+	Count    int         `json:"count"`
+	Next     *string     `json:"next"`
+	Previous *string     `json:"previous"`
+	Results  []VMCluster `json:"results"`
+}
+
+// VMInterface is a Netbox VM interface along with the VM it belongs to
+type VMInterface struct {
+	ID             int                  `json:"id"`
+	URL            string               `json:"url"`
+	Name           string               `json:"name"`
+	VirtualMachine netbox.DisplayIDName `json:"virtual_machine"`
+}
+
+type VMInterfaceSearchResults struct {
+	Count    int           `json:"count"`
+	Next     *string       `json:"next"`
+	Previous *string       `json:"previous"`
+	Results  []VMInterface `json:"results"`
+}
+
+// VLAN is a Netbox VLAN along with the site or VLAN group it is scoped to.
+// Global VLANs have neither.
+type VLAN struct {
+	ID    int                   `json:"id"`
+	Name  string                `json:"name"`
+	VID   int                   `json:"vid"`
+	Site  *netbox.DisplayIDName `json:"site"`
+	Group *netbox.DisplayIDName `json:"group"`
+}
+
+type VLANSearchResults struct {
+	Count    int     `json:"count"`
+	Next     *string `json:"next"`
+	Previous *string `json:"previous"`
+	Results  []VLAN  `json:"results"`
+}
+
 type Netbox interface {
-	GetVM(id string) (vm NBVM, err error)
 	Compare(vm NBVM, pVm VM) map[string]interface{}
 	UpdateVM(map[string]interface{})
 	AddVM(vm VM)
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNICAddIP(t *testing.T) {
+	type added struct {
+		ip     string
+		source string
+	}
+	tests := []struct {
+		name      string
+		added     []added
+		ips       []string
+		sources   map[string]string
+		preferred []string
+	}{
+		{
+			name:      "config only",
+			added:     []added{{"10.0.0.5/24", IPSourceConfig}, {"10.0.1.5/24", IPSourceCloudInit}},
+			ips:       []string{"10.0.0.5/24", "10.0.1.5/24"},
+			sources:   map[string]string{"10.0.0.5/24": IPSourceConfig, "10.0.1.5/24": IPSourceCloudInit},
+			preferred: []string{"10.0.0.5/24", "10.0.1.5/24"},
+		},
+		{
+			name:      "agent replaces config",
+			added:     []added{{"10.0.0.5/32", IPSourceConfig}, {"10.0.0.5/24", IPSourceAgent}},
+			ips:       []string{"10.0.0.5/24"},
+			sources:   map[string]string{"10.0.0.5/24": IPSourceAgent},
+			preferred: []string{"10.0.0.5/24"},
+		},
+		{
+			name:      "agent replaces cloud-init in place",
+			added:     []added{{"10.0.0.4/24", IPSourceConfig}, {"10.0.0.5/16", IPSourceCloudInit}, {"10.0.0.5/24", IPSourceAgent}},
+			ips:       []string{"10.0.0.4/24", "10.0.0.5/24"},
+			sources:   map[string]string{"10.0.0.4/24": IPSourceConfig, "10.0.0.5/24": IPSourceAgent},
+			preferred: []string{"10.0.0.5/24"},
+		},
+		{
+			name:      "config does not replace agent",
+			added:     []added{{"10.0.0.5/24", IPSourceAgent}, {"10.0.0.5/32", IPSourceConfig}, {"10.0.0.5/16", IPSourceCloudInit}},
+			ips:       []string{"10.0.0.5/24"},
+			sources:   map[string]string{"10.0.0.5/24": IPSourceAgent},
+			preferred: []string{"10.0.0.5/24"},
+		},
+		{
+			name:      "first agent address wins",
+			added:     []added{{"10.0.0.5/24", IPSourceAgent}, {"10.0.0.5/16", IPSourceAgent}},
+			ips:       []string{"10.0.0.5/24"},
+			sources:   map[string]string{"10.0.0.5/24": IPSourceAgent},
+			preferred: []string{"10.0.0.5/24"},
+		},
+		{
+			name:      "IPv6 ignoring case",
+			added:     []added{{"2001:DB8::5/128", IPSourceConfig}, {"2001:db8::5/64", IPSourceAgent}},
+			ips:       []string{"2001:db8::5/64"},
+			sources:   map[string]string{"2001:db8::5/64": IPSourceAgent},
+			preferred: []string{"2001:db8::5/64"},
+		},
+		{
+			name:      "agent and config addresses",
+			added:     []added{{"10.0.0.5/24", IPSourceConfig}, {"192.168.1.5/24", IPSourceAgent}},
+			ips:       []string{"10.0.0.5/24", "192.168.1.5/24"},
+			sources:   map[string]string{"10.0.0.5/24": IPSourceConfig, "192.168.1.5/24": IPSourceAgent},
+			preferred: []string{"192.168.1.5/24"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nic := NIC{}
+			for _, a := range test.added {
+				nic.AddIP(a.ip, a.source)
+			}
+			if !reflect.DeepEqual(nic.IP, test.ips) || !reflect.DeepEqual(nic.IPSources, test.sources) {
+				t.Errorf("IPs %v from %v, expected %v from %v", nic.IP, nic.IPSources, test.ips, test.sources)
+			}
+			if preferred := nic.PreferredIPs(); !reflect.DeepEqual(preferred, test.preferred) {
+				t.Errorf("preferred IPs %v, expected %v", preferred, test.preferred)
+			}
+		})
+	}
+}
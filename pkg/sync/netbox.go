@@ -2,62 +2,44 @@ package sync
 
 import (
 	"fmt"
-	"net/url"
 
 	"github.com/rsapc/netbox"
 )
 
-func (s *Sync) GetVMbyName(clusterID int, name string) (NBVM, error) {
+// GetVMbyID loads the Netbox VM with the given Netbox ID along with its
+// interfaces and IPs
+func (s *Sync) GetVMbyID(id int) (NBVM, error) {
 	vm := &NBVM{}
-	args := []string{
-		fmt.Sprintf("name=%s", url.QueryEscape(name)),
-		fmt.Sprintf("cluster_id=%d", clusterID),
-	}
-	nbVms, err := s.netbox.SearchVMs(args...)
+	nbVms, err := s.netbox.SearchVMs(fmt.Sprintf("id=%d", id))
 	if err != nil {
 		return *vm, err
 	}
-	if len(nbVms) == 0 {
+	if len(nbVms) != 1 {
 		return *vm, netbox.ErrNotFound
 	}
-	if len(nbVms) == 1 {
-		vm = &NBVM{nbVms[0], nil, nil}
-	} else {
-		return *vm, fmt.Errorf("too many VMs returned: %d", len(nbVms))
-	}
+	vm = &NBVM{nbVms[0], nil, nil}
 	err = s.loadVMinterfacesAndIP(vm)
 	return *vm, err
 }
 
-func (s *Sync) GetVM(clusterID int, id string) (NBVM, error) {
-	vm := &NBVM{}
-	args := []string{
-		fmt.Sprintf("cluster_id=%d", clusterID),
-		fmt.Sprintf("cf_vmid=%s", id),
-		fmt.Sprintf("cf_vmprovider=%s", s.vmProvider.GetName()),
-	}
-	nbVms, err := s.netbox.SearchVMs(args...)
-	if err != nil {
-		return *vm, err
+// searchVMClusters searches the Netbox VMs with the given args and returns
+// them along with the cluster they belong to
+func (s *Sync) searchVMClusters(args ...string) ([]VMCluster, error) {
+	vms := make([]VMCluster, 0)
+	result := &VMClusterSearchResults{}
+	if err := s.netbox.Search("virtualmachine", result, args...); err != nil {
+		return vms, err
 	}
-	if len(nbVms) == 0 {
-		return *vm, netbox.ErrNotFound
-	}
-	found := false
-	for _, nbVM := range nbVms {
-		if vmid, ok := nbVM.CustomFieldsMap["vmid"]; ok {
-			if fmt.Sprint(vmid) == id {
-				vm = &NBVM{nbVM, nil, nil}
-				found = true
-				break
-			}
+	vms = append(vms, result.Results...)
+	for result.Next != nil {
+		next := *result.Next
+		result.Next = nil
+		if _, err := s.netbox.GetByURL(next, result); err != nil {
+			return vms, err
 		}
+		vms = append(vms, result.Results...)
 	}
-	if !found {
-		return *vm, netbox.ErrNotFound
-	}
-	err = s.loadVMinterfacesAndIP(vm)
-	return *vm, err
+	return vms, nil
 }
 
 func (s *Sync) loadVMinterfacesAndIP(vm *NBVM) error {
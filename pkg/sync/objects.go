@@ -0,0 +1,213 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rsapc/netbox"
+)
+
+// API paths of the Netbox objects the sync assigns to VMs
+const (
+	rolePath   = "/dcim/device-roles/"
+	tagPath    = "/extras/tags/"
+	tenantPath = "/tenancy/tenants/"
+	vlanPath   = "/ipam/vlans/"
+)
+
+// apiURL returns the full URL of the given Netbox API path
+func (s *Sync) apiURL(path string) string {
+	return fmt.Sprintf("%s/api%s", strings.TrimSuffix(s.netboxURL, "/"), path)
+}
+
+// getOrAddObject returns the ID of the Netbox object at the API path with the
+// given name.  If it does not exist it is created with the name, slug and the
+// extra data.  IDs are cached for the rest of the sync.
+func (s *Sync) getOrAddObject(path string, name string, data map[string]any) (int, error) {
+	key := path + strings.ToLower(name)
+	if id, ok := s.objectIDs[key]; ok {
+		return id, nil
+	}
+	result := &netbox.SearchResults{}
+	if _, err := s.netbox.GetByURL(fmt.Sprintf("%s?name__ie=%s", s.apiURL(path), url.QueryEscape(name)), result); err != nil {
+		return 0, err
+	}
+	var id int
+	if result.Count > 0 {
+		id = result.Results[0].ID
+	} else {
+		payload := map[string]any{"name": name, "slug": netbox.Slugify(name)}
+		for k, v := range data {
+			payload[k] = v
+		}
+		obj, err := s.netbox.AddObjectByURL(s.apiURL(path), payload)
+		if err != nil {
+			s.log.Error("could not create netbox object", "path", path, "name", name, "error", err)
+			return 0, err
+		}
+		newID, ok := obj["id"].(float64)
+		if !ok {
+			return 0, fmt.Errorf("no id returned creating %s %s", path, name)
+		}
+		id = int(newID)
+		s.log.Info("created netbox object", "path", path, "name", name, "id", id)
+	}
+	s.objectIDs[key] = id
+	return id, nil
+}
+
+// getRoleID returns the ID of the VM role with the given name
+func (s *Sync) getRoleID(name string) (int, error) {
+	return s.getOrAddObject(rolePath, name, map[string]any{"vm_role": true})
+}
+
+// getVLANID returns the ID of the VLAN with the VLAN ID that is available
+// on the Netbox VM.  VLANs of the VM's site or VLAN group are preferred over
+// global ones, and if there are several the one with the name is used.  A
+// missing VLAN is only created, in the VM's site, when CREATE_VLANS is set.
+func (s *Sync) getVLANID(vmID int, vid int, name string) (int, error) {
+	key := fmt.Sprintf("%s%d/%d", vlanPath, vmID, vid)
+	if id, ok := s.objectIDs[key]; ok {
+		return id, nil
+	}
+	result := &VLANSearchResults{}
+	if _, err := s.netbox.GetByURL(fmt.Sprintf("%s?vid=%d&available_on_virtualmachine=%d", s.apiURL(vlanPath), vid, vmID), result); err != nil {
+		return 0, err
+	}
+	vlan, err := selectVLAN(result.Results, vid, name)
+	if err != nil {
+		return 0, err
+	}
+	id := vlan.ID
+	if id == 0 {
+		if !s.createVLANs {
+			return 0, fmt.Errorf("VLAN %d not found in Netbox, set CREATE_VLANS to create it", vid)
+		}
+		if id, err = s.addVLAN(vmID, vid, name); err != nil {
+			return 0, err
+		}
+	}
+	s.objectIDs[key] = id
+	return id, nil
+}
+
+// selectVLAN returns the VLAN with the VLAN ID to use from the VLANs
+// available on a VM, or an empty VLAN if there is none
+func selectVLAN(vlans []VLAN, vid int, name string) (VLAN, error) {
+	scoped := make([]VLAN, 0, len(vlans))
+	for _, vlan := range vlans {
+		if vlan.Site != nil || vlan.Group != nil {
+			scoped = append(scoped, vlan)
+		}
+	}
+	if len(scoped) > 0 {
+		vlans = scoped
+	}
+	switch len(vlans) {
+	case 0:
+		return VLAN{}, nil
+	case 1:
+		return vlans[0], nil
+	}
+	for _, vlan := range vlans {
+		if strings.EqualFold(vlan.Name, name) {
+			return vlan, nil
+		}
+	}
+	return VLAN{}, fmt.Errorf("%d VLANs found with VLAN ID %d", len(vlans), vid)
+}
+
+// addVLAN creates the VLAN with the VLAN ID and name in the site of the
+// Netbox VM, or as a global VLAN if the VM has no site
+func (s *Sync) addVLAN(vmID int, vid int, name string) (int, error) {
+	if name == "" {
+		name = fmt.Sprintf("VLAN %d", vid)
+	}
+	payload := map[string]any{"name": name, "vid": vid}
+	vms, err := s.netbox.SearchVMs(fmt.Sprintf("id=%d", vmID))
+	if err != nil {
+		return 0, err
+	}
+	var site string
+	if len(vms) == 1 && vms[0].Site.ID > 0 {
+		payload["site"] = vms[0].Site.ID
+		site = vms[0].Site.Name
+	}
+	obj, err := s.netbox.AddObjectByURL(s.apiURL(vlanPath), payload)
+	if err != nil {
+		return 0, err
+	}
+	newID, ok := obj["id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("no id returned creating VLAN %d", vid)
+	}
+	s.log.Info("created netbox VLAN", "vlan", vid, "name", name, "site", site, "id", int(newID))
+	return int(newID), nil
+}
+
+// getTenantID returns the ID of the tenant with the given name
+func (s *Sync) getTenantID(name string) (int, error) {
+	return s.getOrAddObject(tenantPath, name, nil)
+}
+
+// setVMTenant assigns the tenant to the Netbox VM if it has another tenant
+func (s *Sync) setVMTenant(vmURL string, tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	tenantID, err := s.getTenantID(tenant)
+	if err != nil {
+		return err
+	}
+	current := &struct {
+		Tenant *struct {
+			ID int `json:"id"`
+		} `json:"tenant"`
+	}{}
+	if _, err := s.netbox.GetByURL(vmURL, current); err != nil {
+		return err
+	}
+	if current.Tenant != nil && current.Tenant.ID == tenantID {
+		return nil
+	}
+	return s.netbox.UpdateObjectByURL(vmURL, map[string]any{"tenant": tenantID})
+}
+
+// addVMTags adds the tags to the Netbox VM, keeping the tags it already has
+func (s *Sync) addVMTags(vmURL string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	current := &struct {
+		Tags []struct {
+			ID int `json:"id"`
+		} `json:"tags"`
+	}{}
+	if _, err := s.netbox.GetByURL(vmURL, current); err != nil {
+		return err
+	}
+	ids := make([]int, 0, len(current.Tags)+len(tags))
+	existing := make(map[int]bool)
+	for _, tag := range current.Tags {
+		ids = append(ids, tag.ID)
+		existing[tag.ID] = true
+	}
+	changed := false
+	for _, tag := range tags {
+		id, err := s.getOrAddObject(tagPath, tag, nil)
+		if err != nil {
+			s.log.Warn("could not find or create tag", "tag", tag, "error", err)
+			continue
+		}
+		if !existing[id] {
+			ids = append(ids, id)
+			existing[id] = true
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.netbox.UpdateObjectByURL(vmURL, map[string]any{"tags": ids})
+}
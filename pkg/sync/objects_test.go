@@ -0,0 +1,41 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/rsapc/netbox"
+)
+
+func TestSelectVLAN(t *testing.T) {
+	site := &netbox.DisplayIDName{ID: 1, Name: "East"}
+	group := &netbox.DisplayIDName{ID: 2, Name: "East VLANs"}
+	tests := []struct {
+		name     string
+		vlans    []VLAN
+		network  string
+		expected int
+		err      string
+	}{
+		{name: "none", expected: 0},
+		{name: "global", vlans: []VLAN{{ID: 1, Name: "web"}}, network: "other", expected: 1},
+		{name: "site before global", vlans: []VLAN{{ID: 1, Name: "web"}, {ID: 2, Name: "east-web", Site: site}}, network: "web", expected: 2},
+		{name: "group before global", vlans: []VLAN{{ID: 1, Name: "web"}, {ID: 3, Name: "east-web", Group: group}}, expected: 3},
+		{name: "scoped by name", vlans: []VLAN{{ID: 2, Name: "East-Web", Site: site}, {ID: 3, Name: "east-db", Group: group}}, network: "east-web", expected: 2},
+		{name: "globals by name", vlans: []VLAN{{ID: 1, Name: "web"}, {ID: 4, Name: "db"}}, network: "DB", expected: 4},
+		{name: "ambiguous", vlans: []VLAN{{ID: 2, Name: "web", Site: site}, {ID: 3, Name: "web2", Group: group}}, network: "db", err: "2 VLANs found with VLAN ID 10"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vlan, err := selectVLAN(test.vlans, 10, test.network)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("error %v, expected %s", err, test.err)
+				}
+				return
+			}
+			if err != nil || vlan.ID != test.expected {
+				t.Errorf("VLAN %d, %v, expected %d", vlan.ID, err, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseStatusMap converts a comma separated list of state:status pairs
+// (eg. SUSPENDED:paused,prelaunch:staged) into a map of provider power
+// state to Netbox VM status.  The status may be any status value defined in
+// Netbox, including custom statuses added through FIELD_CHOICES.
+func ParseStatusMap(statuses string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(statuses, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		state, status, ok := strings.Cut(pair, ":")
+		state = strings.ToLower(strings.TrimSpace(state))
+		status = strings.TrimSpace(status)
+		if !ok || state == "" || status == "" {
+			return nil, fmt.Errorf("invalid status mapping %q, expected state:status", pair)
+		}
+		parsed[state] = status
+	}
+	return parsed, nil
+}
+
+// applyStatusMap sets the status of the VM from its power state if the
+// state is mapped.  Otherwise the status set by the provider is kept.
+func (s *Sync) applyStatusMap(vm *VM) {
+	if vm.PowerState == "" {
+		return
+	}
+	if status, ok := s.statusMap[strings.ToLower(vm.PowerState)]; ok {
+		vm.Status = status
+	}
+}
+
+// syncedStatus returns true if the sync sets the status, ie. it is active,
+// offline or a status of the status map.  VMs with other statuses are set
+// by hand and are not decommissioned when they disappear from the provider.
+// Decommissioning is never a synced status so decommissioned VMs age out.
+func (s *Sync) syncedStatus(status string) bool {
+	switch status {
+	case "decommissioning":
+		return false
+	case "active", "offline":
+		return true
+	}
+	for _, mapped := range s.statusMap {
+		if strings.EqualFold(mapped, status) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,16 +15,103 @@ import (
 )
 
 type Sync struct {
-	netbox     *netbox.Client
-	vmProvider VMProvider
-	log        pkg.Logger
+	netbox          *netbox.Client
+	vmProvider      VMProvider
+	log             pkg.Logger
+	netboxURL       string
+	matchOrder      []string
+	ambiguous       []AmbiguousMatch
+	fieldOwners     map[string]string
+	objectIDs       map[string]int
+	containerRole   string
+	containerTag    string
+	metadataRules   []MetadataRule
+	metadataFilters []MetadataFilter
+	statusMap       map[string]string
+	createVLANs     bool
 }
 
-func NewSyncService(netbox *netbox.Client, provider VMProvider, logger pkg.Logger) *Sync {
-	sync := &Sync{netbox: netbox, vmProvider: provider, log: logger}
+// Option configures optional behavior of the sync service
+type Option func(*Sync)
+
+// WithMatchOrder sets the order of the matchers used to find the Netbox VM
+// for a provider VM.  See ParseMatchOrder.
+func WithMatchOrder(order []string) Option {
+	return func(s *Sync) {
+		s.matchOrder = order
+	}
+}
+
+// WithFieldOwnership sets which VM fields are owned by Netbox and will
+// not be overwritten by the sync.  See ParseFieldOwnership.
+func WithFieldOwnership(owners map[string]string) Option {
+	return func(s *Sync) {
+		s.fieldOwners = owners
+	}
+}
+
+// WithNetboxURL sets the Netbox URL used to look up and create the roles
+// and tags assigned to VMs
+func WithNetboxURL(url string) Option {
+	return func(s *Sync) {
+		s.netboxURL = url
+	}
+}
+
+// WithContainerRole sets the VM role assigned to containers
+func WithContainerRole(role string) Option {
+	return func(s *Sync) {
+		s.containerRole = role
+	}
+}
+
+// WithContainerTag sets the tag added to containers
+func WithContainerTag(tag string) Option {
+	return func(s *Sync) {
+		s.containerTag = tag
+	}
+}
+
+// WithMetadataRules sets the rules mapping provider metadata to the tenant,
+// tags and custom fields of VMs.  See ParseMetadataRules.
+func WithMetadataRules(rules []MetadataRule) Option {
+	return func(s *Sync) {
+		s.metadataRules = rules
+	}
+}
+
+// WithMetadataFilters sets the filters that select the provider VMs to
+// sync.  VMs that are filtered out are left alone.  See ParseMetadataFilters.
+func WithMetadataFilters(filters []MetadataFilter) Option {
+	return func(s *Sync) {
+		s.metadataFilters = filters
+	}
+}
+
+// WithStatusMap sets the Netbox status of VMs by their provider power
+// state.  See ParseStatusMap.
+func WithStatusMap(statuses map[string]string) Option {
+	return func(s *Sync) {
+		s.statusMap = statuses
+	}
+}
+
+// WithCreateVLANs sets whether VLANs of VM interfaces that do not exist in
+// Netbox are created.  Otherwise the interfaces are not linked to a VLAN.
+func WithCreateVLANs(create bool) Option {
+	return func(s *Sync) {
+		s.createVLANs = create
+	}
+}
+
+func NewSyncService(netbox *netbox.Client, provider VMProvider, logger pkg.Logger, opts ...Option) *Sync {
+	sync := &Sync{netbox: netbox, vmProvider: provider, log: logger, matchOrder: DefaultMatchOrder, objectIDs: make(map[string]int)}
 	if log, ok := logger.(*slog.Logger); ok {
 		sync.log = log.With("service", "netboxvcenter sync")
 	}
+	for _, opt := range opts {
+		opt(sync)
+	}
 
 	return sync
 }
@@ -37,6 +124,10 @@ func (s *Sync) StartSync() {
 	if err := s.VerifyClusterType(); err != nil {
 		os.Exit(1)
 	}
+	if err := s.verifyMetadataFields(); err != nil {
+		s.log.Error("could not verify or create metadata custom fields", "error", err)
+		os.Exit(1)
+	}
 	s.log.Info("retrieving datacenters")
 	dcs, _ := s.vmProvider.GetDatacenters()
 
@@ -63,70 +154,115 @@ func (s *Sync) StartSync() {
 				log.Fatal(err)
 			}
 			for _, vm := range vms {
+				if vm.Err != nil {
+					s.log.Warn("skipping VM with incomplete details", "vm", vm.Name, "error", vm.Err)
+					continue
+				}
+				if !s.selected(vm) {
+					s.log.Debug("VM filtered out by metadata", "vm", vm.Name)
+					continue
+				}
 				s.processVM(nbCluster, vm)
 			}
 			_ = s.Prune(nbCluster, vms)
 		}
 	}
+	s.ReportAmbiguousMatches()
 }
 
 func (s *Sync) processVM(nbCluster netbox.Cluster, vm VM) {
-	found := false
-	nbVM, err := s.GetVM(nbCluster.ID, vm.ID)
-	if err != nil {
-		if errors.Is(err, netbox.ErrNotFound) {
-			nbVM, err = s.GetVMbyName(nbCluster.ID, vm.Name)
-			if err == nil {
-				found = true
-				s.setIDandProvider(nbVM.URL, vm.ID)
-			} else if errors.Is(err, netbox.ErrNotFound) {
-				if err = s.AddVMtoCluster(nbCluster.ID, vm); err != nil {
-					s.log.Error("error adding VM", "error", err)
-				}
-			}
+	if vm.Type == VMTypeContainer {
+		if s.containerRole != "" {
+			vm.Role = s.containerRole
+		}
+		if s.containerTag != "" {
+			vm.Tags = append(vm.Tags, s.containerTag)
 		}
-	} else {
-		found = true
 	}
-	if found {
-		// Update VM if changed
-		s.UpdateVM(nbVM, vm)
+	s.applyMetadata(&vm)
+	s.applyStatusMap(&vm)
+	nbVM, err := s.MatchVM(nbCluster, vm)
+	if errors.Is(err, netbox.ErrNotFound) {
+		if err = s.AddVMtoCluster(nbCluster.ID, vm); err != nil {
+			s.log.Error("error adding VM", "error", err)
+		}
+		return
 	}
-
+	if err != nil {
+		s.log.Error("could not find VM in Netbox", "vm", vm.Name, "error", err)
+		return
+	}
+	// Update VM if changed
+	s.UpdateVM(nbVM, vm)
 }
 
 // UpdateVM compares the netbox VM to the provider VM and makes updates as necessary
 func (s *Sync) UpdateVM(nbVM NBVM, vm VM) error {
 	doUpdate := false
-	editVM := &netbox.NewVM{Name: vm.Name}
-	if nbVM.Diskspace != vm.Diskspace {
+	editVM := &VMEdit{NewVM: netbox.NewVM{Name: vm.Name}}
+	if s.ownsField("disk") && nbVM.Diskspace != vm.Diskspace {
 		editVM.Diskspace = vm.Diskspace
 		doUpdate = true
 	}
-	if nbVM.Memory != vm.Memory {
+	if s.ownsField("memory") && nbVM.Memory != vm.Memory {
 		editVM.Memory = vm.Memory
 		doUpdate = true
 	}
-	if nbVM.VCPUs != vm.VCPUs {
+	if s.ownsField("vcpus") && nbVM.VCPUs != vm.VCPUs {
 		editVM.VCPUs = vm.VCPUs
 		doUpdate = true
 	}
-	if nbVM.Status.Value != vm.Status {
+	if s.ownsField("status") && nbVM.Status.Value != vm.Status {
 		editVM.Status = vm.Status
 		doUpdate = true
 	}
+	if s.ownsField("serial") && vm.Serial != "" && !strings.EqualFold(nbVM.Serial, vm.Serial) {
+		editVM.Serial = vm.Serial
+		doUpdate = true
+	}
+	// VMs without provider notes keep the description and comments entered
+	// in Netbox
+	if strings.TrimSpace(vm.Description) != "" {
+		if description := noteDescription(vm.Description); s.ownsField("description") && nbVM.Description != description {
+			editVM.Description = &description
+			doUpdate = true
+		}
+		if comments := noteComments(vm.Description); s.ownsField("comments") && strings.TrimSpace(nbVM.Comments) != comments {
+			editVM.Comments = &comments
+			doUpdate = true
+		}
+	}
+	if s.ownsField("role") && vm.Role != "" && !strings.EqualFold(nbVM.Role.Name, vm.Role) {
+		if roleID, err := s.getRoleID(vm.Role); err == nil {
+			editVM.Role = roleID
+			doUpdate = true
+		}
+	}
 	if doUpdate {
 		if err := s.netbox.UpdateObject("virtualmachine", int64(nbVM.ID), editVM); err != nil {
 			s.log.Error("could not update VM", "vm", nbVM.Name, "error", err)
 			return err
 		}
 	}
+	if err := s.addVMTags(nbVM.URL, vm.Tags); err != nil {
+		s.log.Error("could not add tags to VM", "vm", nbVM.Name, "error", err)
+	}
+	if s.ownsField("tenant") {
+		if err := s.setVMTenant(nbVM.URL, vm.Tenant); err != nil {
+			s.log.Error("could not set tenant on VM", "vm", nbVM.Name, "tenant", vm.Tenant, "error", err)
+		}
+	}
+	if changed := changedCustomFields(nbVM, vm); len(changed) > 0 {
+		if err := s.setCustomFields(nbVM.URL, changed); err != nil {
+			s.log.Error("could not set custom fields on VM", "vm", nbVM.Name, "error", err)
+		}
+	}
 
 	// Update any changed interfaces
 	for _, intf := range vm.Network {
 		found, nbint := findInterface(intf, nbVM.Interfaces)
 		if found {
-			s.updateVMInterface(nbint, intf)
+			s.updateVMInterface(nbVM.ID, nbint, intf)
 			s.updateInterfaceIPs(nbVM, nbint, intf)
 		} else {
 			s.addInterface(nbVM.ID, intf)
@@ -138,7 +274,7 @@ func (s *Sync) UpdateVM(nbVM NBVM, vm VM) error {
 
 func (s *Sync) updateInterfaceIPs(nbVM NBVM, nbint netbox.Interface, intf NIC) {
 	nbIPs := getInterfaceIPs(nbVM, nbint.ID)
-	for _, ip := range intf.IP {
+	for _, ip := range intf.PreferredIPs() {
 		found := false
 		for _, nip := range nbIPs {
 			if nip.Address == ip {
@@ -161,7 +297,7 @@ func getInterfaceIPs(nbvm NBVM, intID int) []NetboxIP {
 	return ips
 }
 
-func (s *Sync) updateVMInterface(nbint netbox.Interface, nic NIC) error {
+func (s *Sync) updateVMInterface(vmID int, nbint netbox.Interface, nic NIC) error {
 	data := make(map[string]interface{})
 	nbmac := nbint.GetMacAddress()
 	if nbmac != "" && nic.MAC != "" {
@@ -179,6 +315,9 @@ func (s *Sync) updateVMInterface(nbint netbox.Interface, nic NIC) error {
 			}
 		}
 	}
+	for key, value := range s.changedInterfaceSettings(vmID, nbint, nic) {
+		data[key] = value
+	}
 	if len(data) > 0 {
 		return s.netbox.UpdateObjectByURL(nbint.URL, data)
 	}
@@ -208,6 +347,7 @@ func (s *Sync) AddVMtoCluster(clusterID int, vm VM) error {
 	newvm.Memory = vm.Memory
 	newvm.VCPUs = vm.VCPUs
 	newvm.Status = vm.Status
+	newvm.Description = noteDescription(vm.Description)
 
 	nbVm, err := s.netbox.AddVM(*newvm)
 	if err != nil {
@@ -219,6 +359,35 @@ func (s *Sync) AddVMtoCluster(clusterID int, vm VM) error {
 	if err = s.setIDandProvider(nbVm.URL, vm.ID); err != nil {
 		s.log.Error("could not set vmID on vm", "vm", vm.Name, "error", err)
 	}
+	// Add the fields netbox.NewVM does not support
+	data := make(map[string]any)
+	if vm.Serial != "" {
+		data["serial"] = vm.Serial
+	}
+	if comments := noteComments(vm.Description); comments != "" {
+		data["comments"] = comments
+	}
+	if vm.Role != "" {
+		if roleID, err := s.getRoleID(vm.Role); err == nil {
+			data["role"] = roleID
+		}
+	}
+	if vm.Tenant != "" {
+		if tenantID, err := s.getTenantID(vm.Tenant); err == nil {
+			data["tenant"] = tenantID
+		}
+	}
+	if len(vm.CustomFields) > 0 {
+		data["custom_fields"] = vm.CustomFields
+	}
+	if len(data) > 0 {
+		if err = s.netbox.UpdateObjectByURL(nbVm.URL, data); err != nil {
+			s.log.Error("could not set serial, comments, role, tenant and custom fields on vm", "vm", vm.Name, "error", err)
+		}
+	}
+	if err = s.addVMTags(nbVm.URL, vm.Tags); err != nil {
+		s.log.Error("could not add tags to vm", "vm", vm.Name, "error", err)
+	}
 
 	// Add the interfaces
 	for _, nic := range vm.Network {
@@ -232,7 +401,7 @@ func (s *Sync) addInterface(vmid int, nic NIC) {
 	intf := netbox.InterfaceEdit{
 		Name:        &nic.Name,
 		VM:          &vmid,
-		Description: nic.Description,
+		Description: interfaceDescription(nic),
 	}
 	if nic.MAC != "" {
 		intf.SetMac(s.createMAC(nic.MAC))
@@ -242,7 +411,14 @@ func (s *Sync) addInterface(vmid int, nic NIC) {
 		s.log.Error("could not add interface", "vm", vmid, "nic", nic.Name, "error", err)
 	} else {
 		s.setIDandProvider(newIntf.URL, nic.ID)
-		for _, ipaddr := range nic.IP {
+		settings := s.interfaceSettings(vmid, nic)
+		delete(settings, "description")
+		if len(settings) > 0 {
+			if err = s.netbox.UpdateObjectByURL(newIntf.URL, settings); err != nil {
+				s.log.Error("could not set interface mode, VLAN and state", "vm", vmid, "nic", nic.Name, "error", err)
+			}
+		}
+		for _, ipaddr := range nic.PreferredIPs() {
 			s.addInterfaceIP(newIntf.ID, ipaddr, nic.ID)
 		}
 	}
@@ -374,7 +550,7 @@ func (s *Sync) validateNBvm(vm netbox.DeviceOrVM, pvms []VM) error {
 	}
 	if !found {
 		data := make(map[string]interface{})
-		if vm.Status.Value == "active" || vm.Status.Value == "offline" {
+		if s.syncedStatus(vm.Status.Value) {
 			data["status"] = "decommissioning"
 			s.log.Info("decommissioning VM", "vm", vm.Name)
 			err = s.netbox.UpdateObjectByURL(vm.URL, data)
@@ -0,0 +1,23 @@
+package main
+
+// The providers compiled in.  Other providers are added by importing their
+// package in another file of package main, where they register themselves
+// with the providers package.
+import (
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/ec2"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/external"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/inventory"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/kubevirt"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/libvirt"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/netbox"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/nutanix"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/openstack"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/ovirt"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/proxmox"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/proxmoxdc"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/vmware"
+	_ "github.com/ringsq/netboxvmsync/pkg/providers/xenorchestra"
+)
+
+// defaultProvider is used when PROVIDER is not set
+const defaultProvider = "vmware"